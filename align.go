@@ -0,0 +1,58 @@
+package cron
+
+import "time"
+
+// alignedSchedule moves every activation of the wrapped schedule onto a grid.
+type alignedSchedule struct {
+	grid  time.Duration
+	inner Schedule
+}
+
+// AlignTo returns a Schedule that moves each activation time of inner up to the
+// next multiple of grid, counted from midnight, so that jobs on schedules of
+// any kind across a fleet run in the same few slots. A time on the grid is left
+// as it is; others are always moved later, never earlier. For example, with a
+// grid of 5 minutes, every activation is on :00, :05, :10 and so on.
+//
+// The grid starts over at each midnight, in the location of the times inner
+// returns, so a grid that doesn't divide a day evenly has a shorter last slot.
+// Past midnight of a day with a daylight saving transition, the slots are
+// counted in elapsed time, so they are off the wall clock by as much as the
+// clock moved, unless that is a multiple of grid, as an hour is of 5 minutes.
+//
+// Like jitter, aligning may cause activations of inner to be skipped, when
+// more than one falls into the same slot. A grid that is not positive leaves
+// inner as it is.
+func AlignTo(grid time.Duration, inner Schedule) Schedule {
+	if grid <= 0 {
+		return inner
+	}
+	return &alignedSchedule{grid: grid, inner: inner}
+}
+
+// Next returns the next activation time of the wrapped schedule, moved up onto
+// the grid. An unsatisfiable (zero) time is returned unchanged.
+func (s *alignedSchedule) Next(t time.Time) time.Time {
+	next, _ := s.nextProbe(t)
+	return next
+}
+
+// nextProbe is like Next, but also reports whether the time is a probe, see
+// GatedBy. Probes are left off the grid, so that the gate is checked as often.
+func (s *alignedSchedule) nextProbe(t time.Time) (time.Time, bool) {
+	next, probe := nextProbe(s.inner, t)
+	if next.IsZero() || probe {
+		return next, probe
+	}
+	midnight := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, next.Location())
+	elapsed := next.Sub(midnight)
+	if rest := elapsed % s.grid; rest != 0 {
+		elapsed += s.grid - rest
+	}
+	// Past the last slot of the day, the first of the next one is midnight.
+	end := midnight.AddDate(0, 0, 1)
+	if aligned := midnight.Add(elapsed); aligned.Before(end) {
+		return aligned, false
+	}
+	return end, false
+}
@@ -0,0 +1,45 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlignTo(t *testing.T) {
+	s := AlignTo(5*time.Minute, Every(7*time.Minute))
+	from := getTime("Mon Jul 9 14:00 2012")
+	for i := 0; i < 100; i++ {
+		next := s.Next(from)
+		if !next.After(from) {
+			t.Fatalf("%v: expected a time after it, got %v", from, next)
+		}
+		if next.Minute()%5 != 0 || next.Second() != 0 || next.Nanosecond() != 0 {
+			t.Fatalf("%v: expected a time on the 5 minute grid, got %v", from, next)
+		}
+		from = next
+	}
+
+	// Times on the grid are kept, and the grid starts over at midnight.
+	for _, c := range []struct {
+		grid           time.Duration
+		from, expected string
+	}{
+		{5 * time.Minute, "Mon Jul 9 13:59 2012", "Mon Jul 9 14:00 2012"},
+		{7 * time.Minute, "Mon Jul 9 23:54 2012", "Mon Jul 9 23:55 2012"},
+		{7 * time.Minute, "Mon Jul 9 23:55 2012", "Tue Jul 10 00:00 2012"},
+		{7 * time.Minute, "Tue Jul 10 00:00 2012", "Tue Jul 10 00:07 2012"},
+	} {
+		next := AlignTo(c.grid, Every(time.Minute)).Next(getTime(c.from))
+		if expected := getTime(c.expected); !next.Equal(expected) {
+			t.Errorf("%v on a %v grid: (expected) %v != %v (actual)", c.from, c.grid, expected, next)
+		}
+	}
+
+	inner := Every(time.Minute)
+	if s := AlignTo(0, inner); s != Schedule(inner) {
+		t.Errorf("expected a zero grid to leave the schedule as it is, got %v", s)
+	}
+	if next := AlignTo(time.Minute, &SpecSchedule{}).Next(from); !next.IsZero() {
+		t.Errorf("expected an unsatisfiable schedule to stay so, got %v", next)
+	}
+}
@@ -0,0 +1,193 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// JobWrapper decorates the given Job with some behavior.
+type JobWrapper func(Job) Job
+
+// Chain is a sequence of JobWrappers that decorates submitted jobs with
+// cross-cutting behaviors like logging or synchronization.
+type Chain struct {
+	wrappers []JobWrapper
+}
+
+// NewChain returns a Chain consisting of the given JobWrappers.
+func NewChain(wrappers ...JobWrapper) Chain {
+	return Chain{wrappers}
+}
+
+// Then decorates the given job with all JobWrappers in the chain. The first
+// wrapper is the outermost one:
+//
+//	NewChain(m1, m2, m3).Then(job)
+//
+// is equivalent to:
+//
+//	m1(m2(m3(job)))
+func (c Chain) Then(j Job) Job {
+	for i := range c.wrappers {
+		j = c.wrappers[len(c.wrappers)-i-1](j)
+	}
+	return j
+}
+
+// RecoverHandler handles a panic recovered by Recover from the job of the entry,
+// given the stack of the goroutine that panicked.
+type RecoverHandler func(entry *Entry, recovered interface{}, stack []byte)
+
+// Recover recovers panics in wrapped jobs and logs them, or hands them to the
+// entry's handler if it was added WithRecoverHandler. The panic is returned as
+// the error of the run, so that it is recorded on the job's Entry.
+func Recover(logger *log.Logger) JobWrapper {
+	return func(j Job) Job {
+		return ContextFuncJob(func(ctx context.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					const size = 64 << 10
+					buf := make([]byte, size)
+					buf = buf[:runtime.Stack(buf, false)]
+					if entry, ok := EntryFromContext(ctx); ok && entry.onPanic != nil {
+						entry.onPanic(entry, r, buf)
+					} else {
+						logger.Printf("cron: panic running job: %v\n%s", r, buf)
+					}
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return RunJob(ctx, j)
+		})
+	}
+}
+
+// SkipIfStillRunning skips an invocation of the wrapped job if a previous
+// invocation is still running, logging the skip. An invocation whose context
+// is done, e.g. because the Cron was stopped, is abandoned with its error.
+func SkipIfStillRunning(logger *log.Logger) JobWrapper {
+	return func(j Job) Job {
+		var ch = make(chan struct{}, 1)
+		ch <- struct{}{}
+		return ContextFuncJob(func(ctx context.Context) error {
+			select {
+			case v := <-ch:
+				defer func() { ch <- v }()
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				return RunJob(ctx, j)
+			default:
+				logger.Printf("cron: skipping job: still running")
+				return nil
+			}
+		})
+	}
+}
+
+// DelayIfStillRunning serializes invocations of the wrapped job, delaying an
+// invocation until the previous one is complete. Delays of more than a minute
+// are logged. A delayed invocation whose context is done before its turn
+// comes, e.g. because the Cron was stopped, is abandoned with its error.
+func DelayIfStillRunning(logger *log.Logger) JobWrapper {
+	return func(j Job) Job {
+		var ch = make(chan struct{}, 1)
+		ch <- struct{}{}
+		return ContextFuncJob(func(ctx context.Context) error {
+			start := time.Now()
+			select {
+			case v := <-ch:
+				defer func() { ch <- v }()
+			case <-ctx.Done():
+				logger.Printf("cron: abandoning delayed job: %v", ctx.Err())
+				return ctx.Err()
+			}
+			if err := ctx.Err(); err != nil {
+				logger.Printf("cron: abandoning delayed job: %v", err)
+				return err
+			}
+			if delay := time.Since(start); delay > time.Minute {
+				logger.Printf("cron: delayed job by %v: still running", delay)
+			}
+			return RunJob(ctx, j)
+		})
+	}
+}
+
+// MinInterval returns inner wrapped to skip an invocation if it comes within d
+// of the start of the invocation before it, whether the schedule or a direct
+// call to Run or RunJob made it, so that the job never runs more often than
+// that. Skipped invocations return nil.
+func MinInterval(d time.Duration, inner Job) Job {
+	return MinIntervalClock(d, inner, realClock{})
+}
+
+// MinIntervalClock is like MinInterval, but reads the times from clock, e.g.
+// that given to the Cron WithClock.
+func MinIntervalClock(d time.Duration, inner Job, clock Clock) Job {
+	var (
+		mu   sync.Mutex
+		last time.Time
+	)
+	return ContextFuncJob(func(ctx context.Context) error {
+		mu.Lock()
+		now := clock.Now()
+		if !last.IsZero() && now.Sub(last) < d {
+			mu.Unlock()
+			return nil
+		}
+		last = now
+		mu.Unlock()
+		return RunJob(ctx, inner)
+	})
+}
+
+// Retry re-runs the wrapped job when it returns an error, up to the given
+// number of retries, waiting backoff(n) before the n-th retry (starting at 1).
+// It stops on the first success, and gives up early if the context is done or
+// its deadline would pass before the next retry. Only ErrJobs can fail; other
+// jobs are run once.
+func Retry(retries int, backoff func(retry int) time.Duration) JobWrapper {
+	return func(j Job) Job {
+		return ContextFuncJob(func(ctx context.Context) error {
+			err := RunJob(ctx, j)
+			for retry := 1; err != nil && retry <= retries; retry++ {
+				delay := backoff(retry)
+				if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+					return err
+				}
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return err
+				}
+				err = RunJob(ctx, j)
+			}
+			return err
+		})
+	}
+}
+
+// ConstantBackoff returns a backoff for Retry that always waits d.
+func ConstantBackoff(d time.Duration) func(retry int) time.Duration {
+	return func(int) time.Duration { return d }
+}
+
+// ExponentialBackoff returns a backoff for Retry that waits base before the
+// first retry and doubles the wait for each retry after it, up to max.
+func ExponentialBackoff(base, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
@@ -0,0 +1,323 @@
+package cron
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func appendingWrapper(calls *[]string, name string) JobWrapper {
+	return func(j Job) Job {
+		return FuncJob(func() {
+			*calls = append(*calls, name)
+			j.Run()
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	job := FuncJob(func() { calls = append(calls, "job") })
+	NewChain(
+		appendingWrapper(&calls, "first"),
+		appendingWrapper(&calls, "second"),
+		appendingWrapper(&calls, "third"),
+	).Then(job).Run()
+
+	if actual := strings.Join(calls, ","); actual != "first,second,third,job" {
+		t.Errorf("(expected) first,second,third,job != %s (actual)", actual)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	job := NewChain(Recover(log.New(&buf, "", 0))).Then(FuncJob(func() { panic("boom") }))
+
+	err := RunJob(context.Background(), job)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic as error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "boom") {
+		t.Errorf("expected the panic to be logged, got %q", buf.String())
+	}
+}
+
+// Test that Recover hands the panics of an entry added WithRecoverHandler to its
+// handler, and logs those of the others.
+func TestRecoverHandler(t *testing.T) {
+	lines := make(logLines, 10)
+	type recovered struct {
+		id    int64
+		value interface{}
+		stack []byte
+	}
+	handled := make(chan recovered, 1)
+	cron := New(WithChain(Recover(log.New(lines, "", 0))))
+	critical, _ := cron.AddFunc("* * * * * ?", func() { panic("critical") }, WithRecoverHandler(func(e *Entry, r interface{}, stack []byte) {
+		select {
+		case handled <- recovered{e.ID, r, stack}:
+		default:
+		}
+	}))
+	cron.AddFunc("* * * * * ?", func() { panic("other") })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	select {
+	case r := <-handled:
+		if r.id != critical || r.value != "critical" || len(r.stack) == 0 {
+			t.Errorf("unexpected recovered panic %+v", r)
+		}
+	case <-time.After(2 * ONE_SECOND):
+		t.Fatal("expected the handler to be called")
+	}
+	select {
+	case line := <-lines:
+		if !strings.Contains(line, "other") {
+			t.Errorf("expected only the other panic to be logged, got %q", line)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the other panic to be logged")
+	}
+}
+
+func TestSkipIfStillRunning(t *testing.T) {
+	var buf bytes.Buffer
+	release := make(chan struct{})
+	var mu sync.Mutex
+	runs := 0
+	job := NewChain(SkipIfStillRunning(log.New(&buf, "", 0))).Then(FuncJob(func() {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() { job.Run(); close(done) }()
+	for {
+		mu.Lock()
+		n := runs
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	job.Run() // skipped
+	close(release)
+	<-done
+	job.Run() // runs again
+
+	if runs != 2 {
+		t.Errorf("(expected) 2 != %d (actual)", runs)
+	}
+}
+
+func TestDelayIfStillRunning(t *testing.T) {
+	var mu sync.Mutex
+	running, overlapped := 0, false
+	job := NewChain(DelayIfStillRunning(log.New(&bytes.Buffer{}, "", 0))).Then(FuncJob(func() {
+		mu.Lock()
+		running++
+		overlapped = overlapped || running > 1
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() { job.Run(); wg.Done() }()
+	}
+	wg.Wait()
+	if overlapped {
+		t.Error("invocations overlapped")
+	}
+}
+
+// Test that an invocation delayed behind a running one is abandoned when the
+// Cron stops, instead of running after it.
+func TestDelayIfStillRunningStop(t *testing.T) {
+	var buf bytes.Buffer
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock), WithChain(DelayIfStillRunning(log.New(&buf, "", 0))))
+	started := make(chan struct{}, 2)
+	cron.OnJobStart(func(*Entry) { started <- struct{}{} })
+	release := make(chan struct{})
+	var mu sync.Mutex
+	runs := 0
+	cron.AddFunc("* * * * * ?", func() {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		<-release
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cron.Start(ctx)
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		select {
+		case <-started:
+		case <-time.After(ONE_SECOND):
+			t.Fatal("expected the job to start")
+		}
+	}
+
+	cancel()
+	for cron.IsRunning() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if runs != 1 {
+		t.Errorf("(expected) 1 != %d (actual) runs", runs)
+	}
+}
+
+// Test that WithChain decorates jobs added to the Cron.
+func TestMinInterval(t *testing.T) {
+	const d = time.Minute
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012"))
+	var starts []time.Time
+	job := MinIntervalClock(d, FuncJob(func() {
+		starts = append(starts, clock.Now())
+	}), clock)
+
+	// Fired every 25s, the job runs every 75s.
+	for i := 0; i < 12; i++ {
+		job.Run()
+		clock.Advance(25 * time.Second)
+	}
+	if len(starts) != 4 {
+		t.Fatalf("expected the job to run 4 times, ran %d times", len(starts))
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < d {
+			t.Errorf("run %d: expected a gap of at least %v, got %v", i, d, gap)
+		}
+	}
+}
+
+func TestWithChain(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	wrapped := make(chan struct{}, 1)
+	cron := New(WithClock(clock), WithChain(func(j Job) Job {
+		return FuncJob(func() {
+			j.Run()
+			wrapped <- struct{}{}
+		})
+	}))
+	cron.AddFunc("* * * * * ?", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	select {
+	case <-wrapped:
+	case <-time.After(ONE_SECOND):
+		t.Fatal("job was not wrapped")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	job := NewChain(Retry(3, ConstantBackoff(time.Millisecond))).Then(ErrFuncJob(func() error {
+		calls++
+		if calls <= 2 {
+			return errors.New("transient")
+		}
+		return nil
+	}))
+
+	if err := RunJob(context.Background(), job); err != nil {
+		t.Errorf("expected success, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("(expected) 3 != %d (actual) calls", calls)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	calls := 0
+	job := NewChain(Retry(2, ConstantBackoff(time.Millisecond))).Then(ErrFuncJob(func() error {
+		calls++
+		return errors.New("permanent")
+	}))
+
+	if err := RunJob(context.Background(), job); err == nil {
+		t.Error("expected an error")
+	}
+	if calls != 3 {
+		t.Errorf("(expected) 3 != %d (actual) calls", calls)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	calls := 0
+	job := NewChain(Retry(5, ConstantBackoff(time.Hour))).Then(ErrFuncJob(func() error {
+		calls++
+		return errors.New("transient")
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := RunJob(ctx, job); err == nil {
+		t.Error("expected an error")
+	}
+	if calls != 1 {
+		t.Errorf("(expected) 1 != %d (actual) calls", calls)
+	}
+}
+
+// Test that a job that succeeds after retrying counts as a single successful
+// run of its entry.
+func TestRetryRunCount(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	calls := 0
+	cron := New(WithClock(clock), WithChain(Retry(3, ConstantBackoff(time.Millisecond))))
+	id, _ := cron.AddJob("0 * * * * ?", ErrFuncJob(func() error {
+		calls++
+		if calls <= 2 {
+			return errors.New("transient")
+		}
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	entry := waitForEntry(t, cron, id, func(e Entry) bool { return e.finishedCount == 1 })
+	if entry.RunCount != 1 || entry.LastError != nil {
+		t.Errorf("unexpected entry state: %d runs, last error %v", entry.RunCount, entry.LastError)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+	for retry, expected := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if retry == 0 {
+			continue
+		}
+		if actual := backoff(retry); actual != expected {
+			t.Errorf("retry %d: (expected) %v != %v (actual)", retry, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockAfter(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012"))
+	ch := clock.After(time.Minute)
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("fired before the duration elapsed")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case now := <-ch:
+		if expected := getTime("Mon Jul 9 14:01 2012"); now != expected {
+			t.Errorf("(expected) %v != %v (actual)", expected, now)
+		}
+	default:
+		t.Fatal("did not fire after the duration elapsed")
+	}
+}
+
+func TestFakeClockAfterNonPositive(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012"))
+	select {
+	case <-clock.After(0):
+	default:
+		t.Fatal("After(0) should fire immediately")
+	}
+}
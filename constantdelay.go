@@ -3,7 +3,8 @@ package cron
 import "time"
 
 // ConstantDelaySchedule represents a simple recurring duty cycle, e.g. "Every 5 minutes".
-// It does not support jobs more frequent than once a second.
+// It does not support jobs more frequent than once a second; see
+// PreciseDelaySchedule for those.
 type ConstantDelaySchedule struct {
 	Delay time.Duration
 }
@@ -25,3 +26,43 @@ func Every(duration time.Duration) ConstantDelaySchedule {
 func (schedule ConstantDelaySchedule) Next(t time.Time) time.Time {
 	return t.Add(schedule.Delay - time.Duration(t.Nanosecond())*time.Nanosecond)
 }
+
+// FromStart reports true: the first activation is one delay after the Cron
+// starts.
+func (schedule ConstantDelaySchedule) FromStart() bool { return true }
+
+// String returns the spec of the schedule, e.g. "@every 5m0s".
+func (schedule ConstantDelaySchedule) String() string { return "@every " + schedule.Delay.String() }
+
+// PreciseDelaySchedule is like ConstantDelaySchedule, but keeps fractions of a
+// second, for jobs that run more often than once a second, e.g. "Every 250ms".
+type PreciseDelaySchedule struct {
+	Delay time.Duration
+}
+
+// minPreciseDelay is the shortest delay of a PreciseDelaySchedule. In practice
+// the overhead of dispatching a job puts the floor at a few milliseconds, and
+// more under load.
+const minPreciseDelay = time.Millisecond
+
+// EveryPrecise returns a Schedule that activates once every duration, without
+// truncating it to seconds. Delays of less than a millisecond round up to a
+// millisecond.
+func EveryPrecise(duration time.Duration) PreciseDelaySchedule {
+	if duration < minPreciseDelay {
+		duration = minPreciseDelay
+	}
+	return PreciseDelaySchedule{Delay: duration}
+}
+
+// Next returns the next time this should be run.
+func (schedule PreciseDelaySchedule) Next(t time.Time) time.Time {
+	return t.Add(schedule.Delay)
+}
+
+// FromStart reports true: the first activation is one delay after the Cron
+// starts.
+func (schedule PreciseDelaySchedule) FromStart() bool { return true }
+
+// String returns the spec of the schedule, e.g. "@every 250ms".
+func (schedule PreciseDelaySchedule) String() string { return "@every " + schedule.Delay.String() }
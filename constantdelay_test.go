@@ -52,3 +52,26 @@ func TestConstantDelayNext(t *testing.T) {
 		}
 	}
 }
+
+func TestPreciseDelayNext(t *testing.T) {
+	tests := []struct {
+		time     string
+		delay    time.Duration
+		expected string
+	}{
+		{"Mon Jul 9 14:45:00 2012", 250 * time.Millisecond, "Mon Jul 9 14:45:00.25 2012"},
+		{"Mon Jul 9 14:45:00.9 2012", 250 * time.Millisecond, "Mon Jul 9 14:45:01.15 2012"},
+		{"Mon Jul 9 23:59:59.99 2012", 20 * time.Millisecond, "Tue Jul 10 00:00:00.01 2012"},
+
+		// Round up to 1 millisecond if the duration is less.
+		{"Mon Jul 9 14:45:00 2012", time.Microsecond, "Mon Jul 9 14:45:00.001 2012"},
+	}
+
+	for _, c := range tests {
+		actual := EveryPrecise(c.delay).Next(getTime(c.time))
+		expected := getTime(c.expected)
+		if actual != expected {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", c.time, c.delay, expected, actual)
+		}
+	}
+}
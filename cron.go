@@ -3,8 +3,17 @@
 package cron
 
 import (
+	"container/heap"
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -14,12 +23,108 @@ import (
 // be inspected while running.
 type Cron struct {
 	entries   []*Entry
-	add       chan *Entry
-	remove    chan int64
-	removeAll chan struct{}
+	add       chan addition
+	remove    chan removal
+	removeAll chan chan int
+	replace   chan []*Entry
+	exec      chan func()
+	finished  chan jobResult
+	events    chan Event
 	snapshot  chan []*Entry
-	running   bool
+	tick      chan tick
+	running   int32
+	started   int32
 	count     int64
+	clock     Clock
+	chain     Chain
+	parse     func(spec string) (Schedule, error)
+	paused    int32
+	catchUp   bool
+	dedup     bool
+	dayAnd    bool
+	testMode  bool
+	inline    bool
+	inFlight  map[int64]int
+
+	// Whether removing an entry cancels the context of its runs in progress,
+	// see WithCancelOnRemove.
+	cancelOnRemove bool
+
+	// What to do with a run that is due while the entry's job still runs, see
+	// WithOverlapPolicy.
+	overlap OverlapPolicy
+
+	// Whether an entry added WithRunNow may be waiting for its first run.
+	pendingNow bool
+
+	// The limit on jobs running at once set by WithMaxConcurrency, how many are
+	// running, and the runs waiting for one of them to finish.
+	maxConcurrency int
+	active         int
+	queue          []queuedRun
+
+	// Whether to rotate the order of jobs due at once, and by how much.
+	fair bool
+	turn int
+
+	// Whether the run loop has no entry to wait for, see EventIdle.
+	idle bool
+
+	// The share of its interval a run may take before EventJobOverran is
+	// published, see WithOverrunThreshold.
+	overrunThreshold float64
+
+	// How long to sleep with no entry to wait for, or 0 for ten years, and the
+	// longest sleep at all, or 0 for no limit. See WithIdleHorizon and
+	// WithWakeInterval.
+	idleHorizon  time.Duration
+	wakeInterval time.Duration
+
+	// Where to warn about entries waiting for Start, and after how long, see
+	// WithStartWarning.
+	startLogger *log.Logger
+	startGrace  time.Duration
+	startOnce   sync.Once
+
+	// Where to log the runs that are due instead of running them, see
+	// WithDryRun.
+	dryRun *log.Logger
+
+	// Where to log problems of the scheduler itself, see WithLogger.
+	logger *log.Logger
+
+	// Where to log the start and end of each run, see WithRunLogger.
+	runLogger *log.Logger
+
+	// The id of the last run started, see RunIDFromContext.
+	lastRunID int64
+
+	// The source all randomized behaviour draws from, see WithRand.
+	rand *lockedRand
+
+	subscribed int32
+
+	// runMu guards starting and stopping the run loop, and done, which is
+	// closed when the run loop returns.
+	runMu sync.Mutex
+	done  chan struct{}
+
+	// suspend asks the run loop to return, see Suspend, and loopDone is the
+	// done channel of the running loop, for the loop's own use.
+	suspend  chan struct{}
+	loopDone chan struct{}
+
+	// The goroutines of the jobs running, which Stop waits for.
+	jobs sync.WaitGroup
+
+	// entriesMu guards the entries while not running, when callers access
+	// them directly rather than through the run loop.
+	entriesMu sync.Mutex
+
+	hookMu     sync.Mutex
+	onJobStart func(*Entry)
+	onJobEnd   func(*Entry, time.Duration)
+	onStop     func(*Entry)
 }
 
 // Job is an interface for submitted cron jobs.
@@ -27,6 +132,20 @@ type Job interface {
 	Run()
 }
 
+// ErrJob is a Job that reports whether it failed. The error returned by the
+// last run is recorded on the job's Entry.
+type ErrJob interface {
+	Job
+	RunErr() error
+}
+
+// ContextJob is an ErrJob that is handed a context when run by a Cron. The
+// context is cancelled when the Cron is stopped.
+type ContextJob interface {
+	ErrJob
+	RunContext(ctx context.Context) error
+}
+
 // The Schedule describes a job's duty cycle.
 type Schedule interface {
 	// Return the next activation time, later than the given time.
@@ -34,6 +153,68 @@ type Schedule interface {
 	Next(time.Time) time.Time
 }
 
+// A RelativeSchedule is a Schedule that may count its activation times from
+// when it starts, like those of "@every", rather than aligning them to the
+// clock, like those of a cron spec.
+type RelativeSchedule interface {
+	Schedule
+	// FromStart reports whether the activation times count from when the
+	// schedule starts. If so, the Cron computes the first one from the time
+	// it is started, even if the entry already has one.
+	FromStart() bool
+}
+
+// fromStart reports whether the schedule, or the one it wraps, counts its
+// activation times from when it starts.
+func fromStart(schedule Schedule) bool {
+	switch s := schedule.(type) {
+	case *jitterSchedule:
+		return fromStart(s.inner)
+	case *locationSchedule:
+		return fromStart(s.Schedule)
+	case RelativeSchedule:
+		return s.FromStart()
+	}
+	return false
+}
+
+// innerSchedules returns the schedules the schedule wraps, if it is one of the
+// wrappers of this package, e.g. those given to AnyOf.
+func innerSchedules(schedule Schedule) []Schedule {
+	switch s := schedule.(type) {
+	case *jitterSchedule:
+		return []Schedule{s.inner}
+	case *locationSchedule:
+		return []Schedule{s.Schedule}
+	case *alignedSchedule:
+		return []Schedule{s.inner}
+	case *gatedSchedule:
+		return []Schedule{s.inner}
+	case unionSchedule:
+		return s
+	}
+	return nil
+}
+
+// NextIterator returns a func that returns the activation times of s after
+// from, one per call, for running jobs without a Cron. Once s is not activated
+// again, or returns a time that is not after the one before, it returns the
+// zero time.
+func NextIterator(s Schedule, from time.Time) func() time.Time {
+	last := from
+	return func() time.Time {
+		if last.IsZero() {
+			return last
+		}
+		next := s.Next(last)
+		if !next.After(last) {
+			next = time.Time{}
+		}
+		last = next
+		return next
+	}
+}
+
 // Entry consists of a schedule and the func to execute on that schedule.
 type Entry struct {
 	// The schedule on which this job should be run.
@@ -44,7 +225,7 @@ type Entry struct {
 	Next time.Time
 
 	// The last time this job was run. This is the zero time if the job has never
-	// been run.
+	// been run. Runs that are skipped, e.g. while paused, don't count.
 	Prev time.Time
 
 	// The Job to run.
@@ -53,10 +234,130 @@ type Entry struct {
 	// The identifier to reference the job instance.
 	ID int64
 
-	// 0: normal, 1: paused
-	Status int
+	// The name to reference the job instance by, if it was added with one.
+	Name string
+
+	// The spec the job was added with, if any.
+	Spec string
+
+	// Labels for grouping entries, set with WithLabels when adding the job.
+	Labels map[string]string
+
+	// Caller data set with WithMeta when adding the job, e.g. a description
+	// or a pointer to related state. The package never looks at it.
+	Meta interface{}
+
+	// The order among entries due at the same time, set with WithPriority: the
+	// higher, the sooner the job is started. Jobs started one after the other
+	// still run at the same time, unless WithInlineExecution runs them one at
+	// a time, so only then do they also finish in this order.
+	Priority int
+
+	// The group the entry belongs to, if it was added WithGroup, for removing
+	// the whole group at once with RemoveGroup.
+	GroupID string
+
+	// Whether the job is run when due.
+	Status Status
+
+	// The number of times the job has been run since the entry was added.
+	RunCount int64
+
+	// The error returned by the last run of an ErrJob, and when that run
+	// ended. Both are cleared by a successful run.
+	LastError     error
+	LastErrorTime time.Time
+
+	// How long the last run took, and the mean over all finished runs.
+	LastDuration time.Duration
+	AvgDuration  time.Duration
+
+	// How long after it was due the last finished run started, e.g. because
+	// the scheduler was busy or it waited for WithMaxConcurrency.
+	LastLatency time.Duration
+
+	// The number of finished runs, for maintaining AvgDuration.
+	finishedCount int64
+
+	// The job as added, before decorating it with the Cron's wrappers.
+	cmd Job
+
+	// The handler Recover hands the job's panics to, see WithRecoverHandler.
+	onPanic RecoverHandler
+
+	// The context the runs of the job are handed with WithCancelOnRemove, and
+	// its cancel func, both set on the first run since the Cron was started.
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+
+	// Whether the job is to be run as soon as it is added.
+	runNow bool
+
+	// Whether Next is a probe, at which the job is not run, see GatedBy.
+	probe bool
+
+	// The next run time a job run on the scheduler's goroutine asked for with
+	// Reschedule, while the run loop had the entry off the heap.
+	inlineNext time.Time
+
+	// Whether the next run is to be done within the run loop, see
+	// WithSyncFirstRun.
+	syncRun bool
+
+	// When the runs waiting for the running one to finish were due, see
+	// OverlapQueue.
+	overlapped []time.Time
 }
 
+// queuedRun is a run of an entry's job, due at the given time, that waits for
+// fewer jobs to be running.
+type queuedRun struct {
+	entry *Entry
+	due   time.Time
+}
+
+// jobResult is the outcome of a run of an entry's job.
+type jobResult struct {
+	entry      *Entry
+	err        error
+	due        time.Time
+	start, end time.Time
+	runID      int64
+
+	// The next run time the job asked for with Reschedule, if any.
+	next time.Time
+}
+
+// Status is the state of an entry.
+type Status int
+
+const (
+	// StatusUnknown is reported for ids that don't refer to an entry.
+	StatusUnknown Status = -1
+
+	// StatusRunning is the state of an entry whose job is run when due.
+	StatusRunning Status = 0
+
+	// StatusPaused is the state of an entry whose job is skipped when due.
+	StatusPaused Status = 1
+)
+
+// OverlapPolicy is what a Cron does when an entry is due while its job is still
+// running from an earlier run, see WithOverlapPolicy.
+type OverlapPolicy int
+
+const (
+	// OverlapAllow starts another run of the job alongside the running one.
+	OverlapAllow OverlapPolicy = iota
+
+	// OverlapSkip skips the run, publishing EventJobSkipped.
+	OverlapSkip
+
+	// OverlapQueue starts the run once the running one finishes, along with
+	// any others that are due in the meantime, one after the other.
+	OverlapQueue
+)
+
 // byTime is a wrapper for sorting the entry array by time
 // (with zero time at the end).
 type byTime []*Entry
@@ -73,17 +374,59 @@ func (s byTime) Less(i, j int) bool {
 	if s[j].Next.IsZero() {
 		return true
 	}
+	if s[i].Next.Equal(s[j].Next) {
+		return s[i].Priority > s[j].Priority
+	}
 	return s[i].Next.Before(s[j].Next)
 }
 
-// New returns a new Cron job runner.
-func New() *Cron {
-	return &Cron{
-		add:       make(chan *Entry),
+// Push and Pop make byTime a heap.Interface, so that the run loop can keep its
+// entries as a heap, finding the next one to run without sorting them all.
+func (s *byTime) Push(x interface{}) { *s = append(*s, x.(*Entry)) }
+func (s *byTime) Pop() interface{} {
+	old := *s
+	e := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return e
+}
+
+// push adds the entries, keeping the entries a heap while running.
+func (c *Cron) push(entries ...*Entry) {
+	if !c.IsRunning() {
+		c.entries = append(c.entries, entries...)
+		c.watchStart()
+		return
+	}
+	for _, e := range entries {
+		heap.Push((*byTime)(&c.entries), e)
+	}
+}
+
+// New returns a new Cron job runner, modified by the given options.
+func New(opts ...Option) *Cron {
+	c := &Cron{
+		add:       make(chan addition),
 		snapshot:  make(chan []*Entry),
-		remove:    make(chan int64),
-		removeAll: make(chan struct{}),
+		remove:    make(chan removal),
+		removeAll: make(chan chan int),
+		replace:   make(chan []*Entry),
+		exec:      make(chan func()),
+		suspend:   make(chan struct{}),
+		tick:      make(chan tick),
+		finished:  make(chan jobResult),
+		events:    make(chan Event, eventBufferSize),
+		clock:     realClock{},
+		parse:     Parse,
+		inFlight:  make(map[int64]int),
+		rand:      newLockedRand(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		logger:    log.New(os.Stderr, "", log.LstdFlags),
+
+		overrunThreshold: 1,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // A wrapper that turns a func() into a cron.Job
@@ -91,187 +434,2035 @@ type FuncJob func()
 
 func (f FuncJob) Run() { f() }
 
+// A wrapper that turns a func() error into a cron.ErrJob
+type ErrFuncJob func() error
+
+func (f ErrFuncJob) Run()          { f() }
+func (f ErrFuncJob) RunErr() error { return f() }
+
+// A wrapper that turns a func(context.Context) error into a cron.ContextJob
+type ContextFuncJob func(ctx context.Context) error
+
+func (f ContextFuncJob) Run()                                 { f(context.Background()) }
+func (f ContextFuncJob) RunErr() error                        { return f(context.Background()) }
+func (f ContextFuncJob) RunContext(ctx context.Context) error { return f(ctx) }
+
+// RunJob runs the job as a Cron does, handing it ctx if it is a ContextJob, and
+// returning its error if it is an ErrJob. JobWrappers use it to run the job
+// they wrap.
+func RunJob(ctx context.Context, job Job) error {
+	switch j := job.(type) {
+	case ContextJob:
+		return j.RunContext(ctx)
+	case ErrJob:
+		return j.RunErr()
+	}
+	job.Run()
+	return nil
+}
+
+// Errors returned by the Cron, or wrapped by its errors with more detail, for
+// errors.Is.
+var (
+	// ErrInvalidSpec is wrapped by the errors for specs that don't parse.
+	ErrInvalidSpec = errors.New("Invalid spec")
+
+	// ErrNotFound is wrapped by the errors for ids or names of jobs the Cron
+	// doesn't have.
+	ErrNotFound = errors.New("No job")
+
+	// ErrNotRunning is returned by operations that need the scheduler to be
+	// running, like Tick.
+	ErrNotRunning = errors.New("Cron is not running")
+
+	// ErrDuplicate is returned, along with the id of the existing entry, when
+	// adding a job identical to an existing one to a Cron created WithDedup.
+	// It is also wrapped by the error for a job name or id that is already
+	// taken.
+	ErrDuplicate = errors.New("Duplicate job")
+
+	// ErrNilJob is returned when adding a nil job or func, which would only
+	// panic once it is due.
+	ErrNilJob = errors.New("Nil job")
+
+	// ErrLimit is wrapped by the errors ParseWithLimits returns for valid specs
+	// its limits don't allow.
+	ErrLimit = errors.New("Spec exceeds limits")
+)
+
+// checkJob returns ErrNilJob if the job is nil, or is a nil func or pointer.
+func checkJob(job Job) error {
+	if job == nil {
+		return ErrNilJob
+	}
+	switch v := reflect.ValueOf(job); v.Kind() {
+	case reflect.Func, reflect.Ptr:
+		if v.IsNil() {
+			return ErrNilJob
+		}
+	}
+	return nil
+}
+
+// entryKey is the context key of the entry whose job is run.
+type entryKey struct{}
+
+// EntryFromContext returns the snapshot of the entry, taken at dispatch, whose
+// job is run with the context, and whether there is one. The Next of the
+// snapshot is the time the run was due.
+func EntryFromContext(ctx context.Context) (*Entry, bool) {
+	entry, ok := ctx.Value(entryKey{}).(*Entry)
+	return entry, ok
+}
+
+// runIDKey is the context key of the id of the run.
+type runIDKey struct{}
+
+// RunIDFromContext returns the id of the run of the job run with the context,
+// and whether there is one. Each run of a job a Cron starts gets the next id,
+// which is also in the Events of the run and in the lines logged for it with
+// WithRunLogger, so that the job can log it to tie its own lines to those.
+func RunIDFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(runIDKey{}).(int64)
+	return id, ok
+}
+
+// rescheduleKey is the context key of the next run time a job asks for.
+type rescheduleKey struct{}
+
+// rescheduled holds the next run time a job asks for with Reschedule.
+type rescheduled struct {
+	mu sync.Mutex
+	at time.Time
+}
+
+func (r *rescheduled) get() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.at
+}
+
+// Reschedule asks for the next run of the job run with the context, a
+// ContextJob's, to be at the given time rather than the one its schedule gives.
+// It takes effect when the job returns, replacing the entry's next run time;
+// the runs after that follow the schedule again, from the given time on. The
+// last call during a run wins. It reports whether the context is that of a
+// run.
+func Reschedule(ctx context.Context, at time.Time) bool {
+	r, ok := ctx.Value(rescheduleKey{}).(*rescheduled)
+	if !ok {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.at = at
+	return true
+}
+
 // AddFunc adds a func to the Cron to be run on the given schedule.
-func (c *Cron) AddFunc(spec string, cmd func()) (int64, error) {
-	return c.AddJob(spec, FuncJob(cmd))
+func (c *Cron) AddFunc(spec string, cmd func(), opts ...EntryOption) (int64, error) {
+	return c.AddJob(spec, FuncJob(cmd), opts...)
 }
 
-// RemoveJob removes a func from the Cron referenced by the id.
+// RemoveJob removes a func from the Cron referenced by the id. It gives up
+// after a second if the run loop doesn't take the request.
 func (c *Cron) RemoveJob(id int64) {
-	if !c.running {
-		return
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	c.RemoveJobContext(ctx, id)
+}
+
+// RemoveJobContext removes the job referenced by the id, returning the
+// context's error if it is done before the run loop takes the request, or
+// ErrNotFound if there is no such job.
+func (c *Cron) RemoveJobContext(ctx context.Context, id int64) error {
+	var found bool
+	for !c.locked(func() { found = c.removeJob(id) }) {
+		reply := make(chan bool, 1)
+		select {
+		case c.remove <- removal{id, reply}:
+			found = <-reply
+			return notFound(id, found)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.stopped():
+		}
 	}
-	select {
-	case c.remove <- id:
-	case <-time.After(1 * time.Second):
+	return notFound(id, found)
+}
+
+// removal is a request to the run loop to remove the job with the id, which
+// replies whether it was found.
+type removal struct {
+	id    int64
+	found chan bool
+}
+
+// tick is a request to the run loop in test mode to run the entries due by to,
+// which replies the ids of those it ran.
+type tick struct {
+	to  time.Time
+	ran chan []int64
+}
+
+// notFound returns an error wrapping ErrNotFound for the id unless found.
+func notFound(id int64, found bool) error {
+	if found {
+		return nil
 	}
+	return fmt.Errorf("%w with id %d", ErrNotFound, id)
 }
 
-// RemoveAll  removes all jobs
-func (c *Cron) RemoveAll() {
-	if !c.running {
-		c.entries = nil
-		return
+// RemoveAll removes all jobs and returns how many were removed. It gives up
+// after a second, returning 0, if the run loop doesn't take the request.
+func (c *Cron) RemoveAll() int {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	n, _ := c.RemoveAllContext(ctx)
+	return n
+}
+
+// RemoveAllContext removes all jobs and returns how many were removed, or the
+// context's error if it is done before the run loop takes the request.
+func (c *Cron) RemoveAllContext(ctx context.Context) (int, error) {
+	var n int
+	for !c.locked(func() { n = c.removeAllJobs() }) {
+		reply := make(chan int, 1)
+		select {
+		case c.removeAll <- reply:
+			return <-reply, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-c.stopped():
+		}
 	}
+	return n, nil
+}
 
-	select {
-	case c.removeAll <- struct{}{}:
-	case <-time.After(1 * time.Second):
+// ReplaceAll atomically replaces all jobs with the given entries, keeping their
+// ids. When running, no job is dispatched between removing the old entries and
+// adding the new ones, whose next activation times are computed from now. The
+// jobs of entries that are not snapshots of a Cron's are decorated with the
+// Cron's chain of wrappers, as when adding them.
+//
+// It returns an error, changing nothing, if an entry has no schedule, a nil
+// job, wrapping ErrNilJob, or an id that is not positive or that another entry
+// has, wrapping ErrDuplicate.
+func (c *Cron) ReplaceAll(entries []*Entry) error {
+	ids := make(map[int64]bool, len(entries))
+	for _, e := range entries {
+		switch {
+		case e.ID <= 0:
+			return fmt.Errorf("Invalid id %d, ids start at 1", e.ID)
+		case ids[e.ID]:
+			return fmt.Errorf("%w: id %d", ErrDuplicate, e.ID)
+		case e.Schedule == nil:
+			return fmt.Errorf("Entry %d has no schedule", e.ID)
+		}
+		if err := checkJob(e.Job); err != nil {
+			return fmt.Errorf("entry %d: %w", e.ID, err)
+		}
+		ids[e.ID] = true
+	}
+
+	replaced := make([]*Entry, 0, len(entries))
+	for _, e := range entries {
+		c.reserveID(e.ID)
+		// Snapshots carry the job as added and decorated already; an entry
+		// built by the caller is decorated with the Cron's chain here.
+		job, cmd := e.Job, e.cmd
+		if cmd == nil {
+			job, cmd = c.chain.Then(e.Job), e.Job
+		}
+		replaced = append(replaced, &Entry{
+			Schedule: e.Schedule,
+			Job:      job,
+			ID:       e.ID,
+			Name:     e.Name,
+			Spec:     e.Spec,
+			Labels:   copyLabels(e.Labels),
+			Meta:     e.Meta,
+			Priority: e.Priority,
+			GroupID:  e.GroupID,
+			Status:   e.Status,
+			cmd:      cmd,
+			onPanic:  e.onPanic,
+		})
+	}
+	for !c.locked(func() { c.replaceEntries(replaced) }) {
+		select {
+		case c.replace <- replaced:
+			return nil
+		case <-c.stopped():
+		}
+	}
+	return nil
+}
+
+// Diff returns the changes that make the entries of live match those of
+// desired, going by id: snapshots of the entries of desired that live doesn't
+// have, of those of live that desired doesn't have, and of those of desired
+// whose schedule differs from that of live's entry with the same id, as told by
+// SchedulesEqual. Each is in id order. Jobs are not compared.
+//
+// The snapshots of the two Crons are each taken at once, but not at the same
+// point in time.
+func Diff(desired, live *Cron) (toAdd, toRemove, toUpdate []Entry) {
+	byID := func(c *Cron) map[int64]*Entry {
+		entries := map[int64]*Entry{}
+		for _, e := range c.Entries() {
+			entries[e.ID] = e
+		}
+		return entries
 	}
+	want, have := byID(desired), byID(live)
+	for id, e := range want {
+		switch current, ok := have[id]; {
+		case !ok:
+			toAdd = append(toAdd, *e)
+		case !SchedulesEqual(e.Schedule, current.Schedule):
+			toUpdate = append(toUpdate, *e)
+		}
+	}
+	for id, e := range have {
+		if _, ok := want[id]; !ok {
+			toRemove = append(toRemove, *e)
+		}
+	}
+	for _, entries := range [][]Entry{toAdd, toRemove, toUpdate} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	}
+	return toAdd, toRemove, toUpdate
+}
+
+// removeJob removes the job with the id, and reports whether it was found.
+// Runs in progress finish, but don't put the entry back or advance it.
+func (c *Cron) removeJob(id int64) bool {
+	return c.removeEntries(func(e *Entry) bool { return e.ID == id }) > 0
 }
-func (c *Cron) removeJob(id int64) {
+
+// removeEntries removes the entries match returns true for, and returns how
+// many there were.
+func (c *Cron) removeEntries(match func(*Entry) bool) int {
 	w := 0 // write index
 	for _, x := range c.entries {
-		if id == x.ID {
+		if match(x) {
+			x.stopRuns()
 			continue
 		}
 		c.entries[w] = x
 		w++
 	}
+	n := len(c.entries) - w
 	c.entries = c.entries[:w]
+	heap.Init((*byTime)(&c.entries))
+	return n
 }
 
-func (c *Cron) PauseFunc(id int64) {
-	for _, x := range c.entries {
-		if id == x.ID {
-			x.Status = 1
-			break
+// replaceEntries replaces the entries with the given ones, stopping the runs
+// of the old ones whose ids are not among them, as removing them does.
+func (c *Cron) replaceEntries(entries []*Entry) {
+	kept := make(map[int64]bool, len(entries))
+	for _, e := range entries {
+		kept[e.ID] = true
+	}
+	for _, e := range c.entries {
+		if !kept[e.ID] {
+			e.stopRuns()
 		}
 	}
+	c.entries = entries
+}
+
+// removeAllJobs removes all jobs and returns how many there were.
+func (c *Cron) removeAllJobs() int {
+	n := len(c.entries)
+	for _, e := range c.entries {
+		e.stopRuns()
+	}
+	c.entries = nil
+	return n
+}
+
+// stopRuns cancels the context of the runs of the entry's job in progress, if
+// it was set up with WithCancelOnRemove.
+func (e *Entry) stopRuns() {
+	if e.cancelRun != nil {
+		e.cancelRun()
+		e.runCtx, e.cancelRun = nil, nil
+	}
+}
+
+func (c *Cron) PauseFunc(id int64) {
+	c.setStatus(id, StatusPaused)
 }
 
 func (c *Cron) ResumeFunc(id int64) {
-	for _, x := range c.entries {
-		if id == x.ID {
-			x.Status = 0
-			break
+	c.setStatus(id, StatusRunning)
+}
+
+// Disable pauses the entry with the given id, like PauseFunc: it stays in
+// Entries, with its Next advancing, but its job is not run until Enable is
+// called. The Status is kept by ReplaceAll, so entries restored from a
+// snapshot come back disabled. It returns an error wrapping ErrNotFound if
+// there is no such job.
+func (c *Cron) Disable(id int64) error {
+	return notFound(id, c.setStatus(id, StatusPaused))
+}
+
+// Enable resumes the entry with the given id, like ResumeFunc. It returns an
+// error wrapping ErrNotFound if there is no such job.
+func (c *Cron) Enable(id int64) error {
+	return notFound(id, c.setStatus(id, StatusRunning))
+}
+
+func (c *Cron) setStatus(id int64, status Status) bool {
+	var found bool
+	c.do(func() {
+		if e := c.entryByID(id); e != nil {
+			e.Status = status
+			found = true
 		}
-	}
+	})
+	return found
 }
 
-// Status inquires the status of a job, 0: running, 1: paused, -1: not started.
-func (c *Cron) Status(id int) int {
-	for _, x := range c.entries {
-		if id == int(x.ID) {
-			return x.Status
+// PauseAll stops any job from being run until ResumeAll is called. Schedules
+// keep advancing while paused, so the runs that were due in the meantime are
+// skipped. The Status of individual entries is left untouched.
+func (c *Cron) PauseAll() {
+	atomic.StoreInt32(&c.paused, 1)
+}
+
+// ResumeAll resumes running jobs after PauseAll.
+func (c *Cron) ResumeAll() {
+	atomic.StoreInt32(&c.paused, 0)
+}
+
+// OnJobStart sets a func to be called in the job's goroutine right before each
+// run, with a snapshot of the entry taken at dispatch. It replaces any func set
+// before.
+func (c *Cron) OnJobStart(fn func(*Entry)) {
+	c.hookMu.Lock()
+	defer c.hookMu.Unlock()
+	c.onJobStart = fn
+}
+
+// OnJobEnd sets a func to be called in the job's goroutine right after each run
+// returns, with a snapshot of the entry taken at dispatch and how long the run
+// took. It replaces any func set before.
+func (c *Cron) OnJobEnd(fn func(*Entry, time.Duration)) {
+	c.hookMu.Lock()
+	defer c.hookMu.Unlock()
+	c.onJobEnd = fn
+}
+
+// OnStop sets a func to be called by Stop for each entry, in id order, with a
+// snapshot of it, once the jobs running have returned, e.g. to flush state
+// kept per job. It replaces any func set before.
+func (c *Cron) OnStop(fn func(*Entry)) {
+	c.hookMu.Lock()
+	defer c.hookMu.Unlock()
+	c.onStop = fn
+}
+
+// Status inquires the status of a job, or StatusUnknown if there is no such job.
+func (c *Cron) Status(id int) Status {
+	status := StatusUnknown
+	c.do(func() {
+		if e := c.entryByID(int64(id)); e != nil {
+			status = e.Status
 		}
-	}
-	return -1
+	})
+	return status
+}
+
+// AddJob adds a Job to the Cron to be run on the given schedule, and returns
+// the id of the new entry. Ids start at 1; on error, 0 is returned.
+func (c *Cron) AddJob(spec string, cmd Job, opts ...EntryOption) (int64, error) {
+	return c.AddJobContext(context.Background(), spec, cmd, opts...)
 }
 
-// AddFunc adds a Job to the Cron to be run on the given schedule.
-func (c *Cron) AddJob(spec string, cmd Job) (int64, error) {
-	schedule, err := Parse(spec)
+// AddJobContext is like AddJob, but returns the context's error if it is done
+// before the run loop takes the new entry, in which case no job is added.
+func (c *Cron) AddJobContext(ctx context.Context, spec string, cmd Job, opts ...EntryOption) (int64, error) {
+	schedule, err := c.parseSchedule(spec)
 	if err != nil {
-		return -1, err
+		return 0, err
 	}
-	atomic.AddInt64(&c.count, 1)
-	i := atomic.LoadInt64(&c.count)
-	c.Schedule(schedule, cmd, i)
-	return i, nil
+	entry, err := c.addSpec(ctx, spec, schedule, cmd, opts, false)
+	return entry.ID, err
 }
 
-// Schedule adds a Job to the Cron to be run on the given schedule.
-func (c *Cron) Schedule(schedule Schedule, cmd Job, id int64) {
-	entry := &Entry{
-		Schedule: schedule,
-		Job:      cmd,
-		ID:       id,
-		Status:   0,
-	}
-	if !c.running {
-		c.entries = append(c.entries, entry)
-		return
+// AddFuncStandard is like AddFunc, but always parses the spec with
+// ParseStandard, as a standard 5-field crontab spec starting with the minute,
+// whether or not the Cron was created WithoutSeconds.
+func (c *Cron) AddFuncStandard(spec string, cmd func(), opts ...EntryOption) (int64, error) {
+	schedule, err := ParseStandard(spec)
+	if err != nil {
+		return 0, err
 	}
-
-	c.add <- entry
+	entry, err := c.addSpec(context.Background(), spec, schedule, FuncJob(cmd), opts, false)
+	return entry.ID, err
 }
 
-// Entries returns a snapshot of the cron entries.
-func (c *Cron) Entries() []*Entry {
-	if c.running {
-		c.snapshot <- nil
-		x := <-c.snapshot
-		return x
+// AddFuncMulti adds a func to the Cron to be run whenever any of the specs
+// says, as a single entry on their AnyOf schedule, and returns its id. The
+// Spec of the entry is the specs joined by " | ".
+func (c *Cron) AddFuncMulti(specs []string, cmd func(), opts ...EntryOption) (int64, error) {
+	if len(specs) == 0 {
+		return 0, fmt.Errorf("%w: no specs", ErrInvalidSpec)
+	}
+	schedules := make([]Schedule, len(specs))
+	for i, spec := range specs {
+		schedule, err := c.parseSchedule(spec)
+		if err != nil {
+			return 0, err
+		}
+		schedules[i] = schedule
 	}
-	return c.entrySnapshot()
+	entry, err := c.addSpec(context.Background(), strings.Join(specs, " | "), AnyOf(schedules...), FuncJob(cmd), opts, false)
+	return entry.ID, err
 }
 
-// Start the cron scheduler in its own go-routine.
-func (c *Cron) Start(ctx context.Context) {
-	c.running = true
-	go c.run(ctx)
+// parseSchedule parses spec the way the Cron was configured to.
+func (c *Cron) parseSchedule(spec string) (Schedule, error) {
+	schedule, err := c.parse(spec)
+	if err != nil || !c.dayAnd {
+		return schedule, err
+	}
+	return withDayAnd(schedule), nil
 }
 
-// Run the scheduler.. this is private just due to the need to synchronize
-// access to the 'running' state variable.
-func (c *Cron) run(ctx context.Context) {
-	// Figure out the next activation times for each entry.
-	now := time.Now().Local()
-	for _, entry := range c.entries {
-		entry.Next = entry.Schedule.Next(now)
+// addSpec adds the job on the schedule parsed from spec, and returns an entry
+// with the id of the new one, or that of an identical one along with
+// ErrDuplicate. If wait, the entry is a snapshot of the new one as it was added,
+// with its Next computed if the Cron is running.
+func (c *Cron) addSpec(ctx context.Context, spec string, schedule Schedule, cmd Job, opts []EntryOption, wait bool) (Entry, error) {
+	if err := checkJob(cmd); err != nil {
+		return Entry{}, err
+	}
+	opts = append([]EntryOption{withSpec(spec)}, opts...)
+	entry := c.newEntry(schedule, cmd, c.nextID(), opts)
+	if c.dedup {
+		id, err := c.addUnique(entry)
+		if err != nil || !wait {
+			return Entry{ID: id}, err
+		}
+		// addUnique has the entry added by the time it returns.
+		added, _ := c.EntryByID(id)
+		return added, nil
 	}
+	added, err := c.schedule(ctx, entry, wait)
+	if err != nil {
+		return Entry{}, err
+	}
+	if !wait {
+		return Entry{ID: entry.ID}, nil
+	}
+	return added, nil
+}
 
-	for {
-		// Determine the next entry to run.
-		sort.Sort(byTime(c.entries))
+// addUnique adds the entry, unless there is an identical one, whose id is
+// returned along with ErrDuplicate instead. Entries are identical if their
+// specs are the same but for case and spacing, and their jobs are equal. Jobs
+// that can't be compared, like funcs, are considered equal.
+func (c *Cron) addUnique(entry *Entry) (int64, error) {
+	var (
+		id  = entry.ID
+		err error
+	)
+	c.insert(entry, func() error {
+		spec := canonicalSpec(entry.Spec)
+		for _, e := range c.entries {
+			if canonicalSpec(e.Spec) == spec && sameJob(e.cmd, entry.cmd) {
+				id, err = e.ID, ErrDuplicate
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
 
-		var effective time.Time
-		if len(c.entries) == 0 || c.entries[0].Next.IsZero() {
-			// If there are no entries yet, just sleep - it still handles new entries
-			// and stop requests.
-			effective = now.AddDate(10, 0, 0)
-		} else {
-			effective = c.entries[0].Next
+// insert adds the entry, computing its Next when running, unless check
+// returns an error. Both are done in one go, so that check sees the entries as
+// they are when the entry is added.
+func (c *Cron) insert(entry *Entry, check func() error) error {
+	var err error
+	c.do(func() {
+		if err = check(); err != nil {
+			return
 		}
+		if c.IsRunning() {
+			now := c.clock.Now().Local()
+			c.catchUpSun(entry, now)
+			c.firstNext(entry, now)
+		}
+		c.push(entry)
+		c.pendingNow = c.pendingNow || entry.runNow
+	})
+	return err
+}
 
-		select {
-		case now = <-time.After(effective.Sub(now)):
-			// Run every entry whose next time was this effective time.
-			for _, e := range c.entries {
-				if e.Next != effective {
-					break
-				}
-				if e.Status == 0 {
-					go e.Job.Run()
-				}
-				e.Prev = e.Next
-				e.Next = e.Schedule.Next(effective)
+// HasSpec reports whether a job was added with the spec, comparing specs as
+// WithDedup does.
+func (c *Cron) HasSpec(spec string) bool {
+	var found bool
+	spec = canonicalSpec(spec)
+	c.do(func() {
+		for _, e := range c.entries {
+			if e.Spec != "" && canonicalSpec(e.Spec) == spec {
+				found = true
+				return
 			}
-			continue
+		}
+	})
+	return found
+}
 
-		case newEntry := <-c.add:
-			c.entries = append(c.entries, newEntry)
-			newEntry.Next = newEntry.Schedule.Next(now)
+// canonicalSpec returns the spec in lower case with single spaces between the
+// fields.
+func canonicalSpec(spec string) string {
+	return strings.ToLower(strings.Join(strings.Fields(spec), " "))
+}
 
-		case id := <-c.remove:
-			c.removeJob(id)
-		case <-c.removeAll:
-			c.entries = nil
-		case <-c.snapshot:
-			c.snapshot <- c.entrySnapshot()
+// sameJob reports whether the jobs are equal, or can't be compared.
+func sameJob(a, b Job) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb {
+		return false
+	}
+	if ta == nil || !ta.Comparable() {
+		return true
+	}
+	return a == b
+}
 
-		case <-ctx.Done():
-			return
+// BatchAdd adds the jobs to the Cron, each to be run on the spec at the same
+// index, and returns their ids. When running, the whole batch is handed to the
+// run loop at once. If a spec fails to parse, the error names its index and no
+// job is added.
+func (c *Cron) BatchAdd(specs []string, jobs []Job) ([]int64, error) {
+	if len(specs) != len(jobs) {
+		return nil, fmt.Errorf("Expected as many specs as jobs, got %d specs and %d jobs", len(specs), len(jobs))
+	}
+	schedules := make([]Schedule, len(specs))
+	for i, spec := range specs {
+		schedule, err := c.parseSchedule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("spec %d: %w", i, err)
+		}
+		if err := checkJob(jobs[i]); err != nil {
+			return nil, fmt.Errorf("job %d: %w", i, err)
 		}
+		schedules[i] = schedule
+	}
 
-		// 'now' should be updated after newEntry and snapshot cases.
-		now = time.Now().Local()
+	ids := make([]int64, len(jobs))
+	entries := make([]*Entry, len(jobs))
+	for i, job := range jobs {
+		ids[i] = c.nextID()
+		entries[i] = c.newEntry(schedules[i], job, ids[i], []EntryOption{withSpec(specs[i])})
 	}
+	c.do(func() {
+		if c.IsRunning() {
+			now := c.clock.Now().Local()
+			for _, e := range entries {
+				c.catchUpSun(e, now)
+				c.firstNext(e, now)
+			}
+		}
+		c.push(entries...)
+	})
+	return ids, nil
 }
 
-// entrySnapshot returns a copy of the current cron entry list.
-func (c *Cron) entrySnapshot() []*Entry {
-	entries := []*Entry{}
-	for _, e := range c.entries {
-		entries = append(entries, &Entry{
-			Schedule: e.Schedule,
-			Next:     e.Next,
-			Prev:     e.Prev,
-			Job:      e.Job,
-			ID:       e.ID,
-			Status:   e.Status,
+// AddFuncNow adds a func to the Cron to be run right away, and then on the
+// given schedule. See WithRunNow.
+func (c *Cron) AddFuncNow(spec string, cmd func(), opts ...EntryOption) (int64, error) {
+	return c.AddJob(spec, FuncJob(cmd), append(opts, WithRunNow())...)
+}
+
+// AddJobEntry is like AddJob, but returns a snapshot of the new entry. Its
+// Next is computed if the Cron is running, and the zero time otherwise; the
+// run loop hands back the Next it computed when taking the entry, so it is
+// never missing from the snapshot.
+func (c *Cron) AddJobEntry(spec string, cmd Job, opts ...EntryOption) (Entry, error) {
+	schedule, err := c.parseSchedule(spec)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry, err := c.addSpec(context.Background(), spec, schedule, cmd, opts, true)
+	if err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// AddFuncEntry is like AddFunc, but returns a snapshot of the new entry, as
+// AddJobEntry does.
+func (c *Cron) AddFuncEntry(spec string, cmd func(), opts ...EntryOption) (Entry, error) {
+	return c.AddJobEntry(spec, FuncJob(cmd), opts...)
+}
+
+// UpdateSunLocation moves the sun schedules of all entries to the given
+// coordinates, computing their next run times anew, e.g. after the device they
+// run on was moved. That includes those wrapped in other schedules, like those
+// of AnyOf, AlignTo, GatedBy or WithJitter. Other entries are left alone, and
+// so are the coordinates of the sun schedules created later, see
+// SetDefaultLocation. It returns an error, changing nothing, if the coordinates
+// are out of range.
+func (c *Cron) UpdateSunLocation(lat, lng float64) error {
+	if err := checkCoordinates(lat, lng); err != nil {
+		return err
+	}
+	c.do(func() {
+		running := c.IsRunning()
+		now := c.clock.Now().Local()
+		for _, e := range c.entries {
+			schedule, ok := withSunLocation(e.Schedule, lat, lng)
+			if !ok {
+				continue
+			}
+			e.Schedule = schedule
+			if running {
+				e.Next, e.probe = nextProbe(schedule, now)
+			} else {
+				// Start computes it.
+				e.Next = time.Time{}
+			}
+		}
+		if running {
+			heap.Init((*byTime)(&c.entries))
+		}
+	})
+	return nil
+}
+
+// AddFuncCancel is like AddFunc, but returns a func that removes the new entry
+// instead of its id. Calling it more than once, or after the entry was removed
+// otherwise, has no effect.
+func (c *Cron) AddFuncCancel(spec string, cmd func(), opts ...EntryOption) (cancel func(), err error) {
+	id, err := c.AddFunc(spec, cmd, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() { once.Do(func() { c.RemoveJob(id) }) }, nil
+}
+
+// AddSunFunc adds a func to the Cron to be run at the given sun state (e.g.
+// "sunset" or "dusk:nautical") every day, at the given coordinates. The Spec
+// of the entry is the state as a descriptor, e.g. "@sunset".
+func (c *Cron) AddSunFunc(state string, lat, lng float64, cmd func()) (int64, error) {
+	if err := checkCoordinates(lat, lng); err != nil {
+		return 0, err
+	}
+	spec := "@" + state
+	schedule, err := c.parseSchedule(spec)
+	if err != nil {
+		return 0, err
+	}
+	schedule, ok := withSunLocation(schedule, lat, lng)
+	if !ok {
+		return 0, fmt.Errorf("%w: not a sun state: %s", ErrInvalidSpec, state)
+	}
+	entry, err := c.addSpec(context.Background(), spec, schedule, FuncJob(cmd), nil, false)
+	return entry.ID, err
+}
+
+// nextID returns a new unique entry id.
+func (c *Cron) nextID() int64 {
+	return atomic.AddInt64(&c.count, 1)
+}
+
+// reserveID makes sure that nextID never returns the given id.
+func (c *Cron) reserveID(id int64) {
+	for {
+		count := atomic.LoadInt64(&c.count)
+		if id <= count || atomic.CompareAndSwapInt64(&c.count, count, id) {
+			return
+		}
+	}
+}
+
+// Schedule adds a Job to the Cron to be run on the given schedule. The job is
+// decorated with the Cron's chain of wrappers. A nil job is not added.
+func (c *Cron) Schedule(schedule Schedule, cmd Job, id int64, opts ...EntryOption) {
+	c.ScheduleContext(context.Background(), schedule, cmd, id, opts...)
+}
+
+// AddSchedule is like Schedule, but adds the job under an id the Cron picks, as
+// AddJob does, and returns it. For a nil job it adds nothing and returns 0.
+func (c *Cron) AddSchedule(schedule Schedule, cmd Job, opts ...EntryOption) int64 {
+	if checkJob(cmd) != nil {
+		return 0
+	}
+	id := c.nextID()
+	c.Schedule(schedule, cmd, id, opts...)
+	return id
+}
+
+// ScheduleContext is like Schedule, but returns the context's error if it is
+// done before the run loop takes the new entry, in which case no job is added.
+// It returns ErrNilJob, adding nothing, for a nil job.
+func (c *Cron) ScheduleContext(ctx context.Context, schedule Schedule, cmd Job, id int64, opts ...EntryOption) error {
+	if err := checkJob(cmd); err != nil {
+		return err
+	}
+	_, err := c.schedule(ctx, c.newEntry(schedule, cmd, id, opts), false)
+	return err
+}
+
+// addition is a request to the run loop to add the entry, which replies with a
+// snapshot of it as added if added is not nil.
+type addition struct {
+	entry *Entry
+	added chan Entry
+}
+
+// schedule adds the entry, and if wait, returns a snapshot of it as added, with
+// its Next computed if the Cron is running. It returns the context's error if
+// it is done before the run loop takes the entry, in which case it is not
+// added.
+func (c *Cron) schedule(ctx context.Context, entry *Entry, wait bool) (Entry, error) {
+	var added Entry
+	for !c.locked(func() {
+		c.push(entry)
+		c.pendingNow = c.pendingNow || entry.runNow
+		added = *entry.snapshot()
+	}) {
+		req := addition{entry: entry}
+		if wait {
+			req.added = make(chan Entry, 1)
+			added = *entry.snapshot()
+		}
+		select {
+		case c.add <- req:
+			if !wait {
+				return Entry{}, nil
+			}
+			select {
+			case added = <-req.added:
+			case <-c.stopped():
+				// The entry was left in the buffer of adds, for the run loop
+				// to take when started again, so it has no Next yet.
+			}
+			return added, nil
+		case <-ctx.Done():
+			return Entry{}, ctx.Err()
+		case <-c.stopped():
+		}
+	}
+	return added, nil
+}
+
+// newEntry returns an entry for the job, decorated with the Cron's chain of
+// wrappers, modified by the given options.
+func (c *Cron) newEntry(schedule Schedule, cmd Job, id int64, opts []EntryOption) *Entry {
+	entry := &Entry{
+		Schedule: withRand(schedule, c.rand),
+		Job:      c.chain.Then(cmd),
+		ID:       id,
+		Status:   StatusRunning,
+		cmd:      cmd,
+	}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	return entry
+}
+
+// AddFuncNamed adds a func to the Cron to be run on the given schedule, under
+// a name by which it can be referenced instead of its id. It returns an error
+// if another entry has the name.
+func (c *Cron) AddFuncNamed(name, spec string, cmd func(), opts ...EntryOption) error {
+	return c.AddJobNamed(name, spec, FuncJob(cmd), opts...)
+}
+
+// AddJobNamed is AddFuncNamed for a Job.
+func (c *Cron) AddJobNamed(name, spec string, cmd Job, opts ...EntryOption) error {
+	if name == "" {
+		return fmt.Errorf("Job name must not be empty")
+	}
+	if err := checkJob(cmd); err != nil {
+		return err
+	}
+	schedule, err := c.parseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	entry := c.newEntry(schedule, cmd, c.nextID(), append([]EntryOption{withSpec(spec)}, opts...))
+	entry.Name = name
+
+	// Check for the name when adding, so that concurrent adds can't both take
+	// it.
+	return c.insert(entry, func() error {
+		if c.entryByName(name) != nil {
+			return fmt.Errorf("%w name: %q", ErrDuplicate, name)
+		}
+		return nil
+	})
+}
+
+// AddFuncWithID adds a func to the Cron to be run on the given schedule, under
+// the given id rather than one the Cron picks, e.g. to keep ids stable across
+// restarts. It returns an error wrapping ErrDuplicate if another entry has the
+// id. The ids the Cron picks afterwards are greater than it.
+func (c *Cron) AddFuncWithID(id int64, spec string, cmd func(), opts ...EntryOption) error {
+	return c.AddJobWithID(id, spec, FuncJob(cmd), opts...)
+}
+
+// AddJobWithID is AddFuncWithID for a Job.
+func (c *Cron) AddJobWithID(id int64, spec string, cmd Job, opts ...EntryOption) error {
+	if id <= 0 {
+		return fmt.Errorf("Job id must be positive: %d", id)
+	}
+	if err := checkJob(cmd); err != nil {
+		return err
+	}
+	schedule, err := c.parseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	c.reserveID(id)
+	entry := c.newEntry(schedule, cmd, id, append([]EntryOption{withSpec(spec)}, opts...))
+	return c.insert(entry, func() error {
+		if c.entryByID(id) != nil {
+			return fmt.Errorf("%w id: %d", ErrDuplicate, id)
+		}
+		return nil
+	})
+}
+
+// entryByName returns the entry with the given name, or nil.
+func (c *Cron) entryByName(name string) *Entry {
+	for _, e := range c.entries {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// EntryByName returns a snapshot of the entry with the given name, and whether
+// it was found.
+func (c *Cron) EntryByName(name string) (Entry, bool) {
+	var (
+		entry Entry
+		found bool
+	)
+	c.do(func() {
+		if e := c.entryByName(name); e != nil {
+			entry, found = *e.snapshot(), true
+		}
+	})
+	return entry, found
+}
+
+// RemoveByName removes the entry with the given name, and reports whether it
+// was found.
+func (c *Cron) RemoveByName(name string) bool {
+	var found bool
+	c.do(func() {
+		if e := c.entryByName(name); e != nil {
+			c.removeJob(e.ID)
+			found = true
+		}
+	})
+	return found
+}
+
+// RemoveGroup removes all entries added WithGroup with the given group id, in
+// one go, so that no job of the group runs once any is removed. It returns how
+// many were removed.
+func (c *Cron) RemoveGroup(groupID string) int {
+	if groupID == "" {
+		return 0
+	}
+	var n int
+	c.do(func() {
+		n = c.removeEntries(func(e *Entry) bool { return e.GroupID == groupID })
+	})
+	return n
+}
+
+// PauseByName pauses the entry with the given name, and reports whether it was
+// found.
+func (c *Cron) PauseByName(name string) bool {
+	return c.setStatusByName(name, StatusPaused)
+}
+
+// ResumeByName resumes the entry with the given name, and reports whether it
+// was found.
+func (c *Cron) ResumeByName(name string) bool {
+	return c.setStatusByName(name, StatusRunning)
+}
+
+func (c *Cron) setStatusByName(name string, status Status) bool {
+	var found bool
+	c.do(func() {
+		if e := c.entryByName(name); e != nil {
+			e.Status = status
+			found = true
+		}
+	})
+	return found
+}
+
+// hasLabel reports whether the entry has the label key set to value.
+func (e *Entry) hasLabel(key, value string) bool {
+	v, ok := e.Labels[key]
+	return ok && v == value
+}
+
+// EntriesByLabel returns snapshots of the entries with the label key set to
+// value.
+func (c *Cron) EntriesByLabel(key, value string) []Entry {
+	var entries []Entry
+	c.do(func() {
+		for _, e := range c.entries {
+			if e.hasLabel(key, value) {
+				entries = append(entries, *e.snapshot())
+			}
+		}
+	})
+	return entries
+}
+
+// PauseByLabel pauses the entries with the label key set to value, and returns
+// how many there are.
+func (c *Cron) PauseByLabel(key, value string) int {
+	return c.setStatusByLabel(key, value, StatusPaused)
+}
+
+// ResumeByLabel resumes the entries with the label key set to value, and
+// returns how many there are.
+func (c *Cron) ResumeByLabel(key, value string) int {
+	return c.setStatusByLabel(key, value, StatusRunning)
+}
+
+func (c *Cron) setStatusByLabel(key, value string, status Status) int {
+	var n int
+	c.do(func() {
+		for _, e := range c.entries {
+			if e.hasLabel(key, value) {
+				e.Status = status
+				n++
+			}
+		}
+	})
+	return n
+}
+
+// Running returns the ids of the entries whose job is running, in ascending
+// order.
+func (c *Cron) Running() []int64 {
+	var ids []int64
+	c.do(func() {
+		for id := range c.inFlight {
+			ids = append(ids, id)
+		}
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Entries returns a snapshot of the cron entries, the next to run first. If the
+// run loop returns while it waits for it, e.g. as the Cron's context is
+// cancelled, the snapshot is taken directly instead.
+func (c *Cron) Entries() []*Entry {
+	var entries []*Entry
+	for !c.locked(func() { entries = c.entrySnapshot() }) {
+		select {
+		case c.snapshot <- nil:
+			return <-c.snapshot
+		case <-c.stopped():
+		}
+	}
+	return entries
+}
+
+// ForEach calls fn with a snapshot of each entry, in no particular order, until
+// it returns false. Unlike Entries it doesn't build a slice of them all. When
+// running, fn is called on the scheduler's goroutine, so it must return quickly
+// and must not call the Cron's methods.
+func (c *Cron) ForEach(fn func(Entry) bool) {
+	c.do(func() {
+		for _, e := range c.entries {
+			if !fn(*e.snapshot()) {
+				return
+			}
+		}
+	})
+}
+
+// EntryByID returns a snapshot of the entry with the given id, and whether it
+// was found.
+func (c *Cron) EntryByID(id int64) (Entry, bool) {
+	var (
+		entry Entry
+		found bool
+	)
+	c.do(func() {
+		if e := c.entryByID(id); e != nil {
+			entry, found = *e.snapshot(), true
+		}
+	})
+	return entry, found
+}
+
+// ReplaceJob swaps the job of the entry with the given id, keeping its
+// schedule, id and run times. The new job is decorated with the Cron's chain of
+// wrappers, and runs from the next activation on; a run in progress finishes
+// with the old job.
+func (c *Cron) ReplaceJob(id int64, job Job) error {
+	if err := checkJob(job); err != nil {
+		return err
+	}
+	wrapped := c.chain.Then(job)
+	var found bool
+	c.do(func() {
+		if e := c.entryByID(id); e != nil {
+			e.Job, e.cmd = wrapped, job
+			found = true
+		}
+	})
+	return notFound(id, found)
+}
+
+// Reset recomputes the next run time of the entry with the given id from now,
+// keeping its schedule, e.g. after the clock changed. It returns an error
+// wrapping ErrNotFound if there is no such job.
+func (c *Cron) Reset(id int64) error {
+	var found bool
+	c.do(func() {
+		if e := c.entryByID(id); e != nil {
+			next, probe := nextProbe(e.Schedule, c.clock.Now().Local())
+			c.reschedule(e, next)
+			e.probe = probe
+			found = true
+		}
+	})
+	return notFound(id, found)
+}
+
+// SetNext makes the job with the given id run next at t rather than when its
+// schedule says, after which the schedule takes over again. A t of now runs it
+// right away; an earlier one is an error, as is an id of no job, wrapping
+// ErrNotFound. Set before Start, t is kept unless it has gone by, or the
+// schedule counts from Start, see RelativeSchedule.
+func (c *Cron) SetNext(id int64, t time.Time) error {
+	var found bool
+	var err error
+	c.do(func() {
+		e := c.entryByID(id)
+		if e == nil {
+			return
+		}
+		found = true
+		if now := c.clock.Now(); t.Before(now) {
+			err = fmt.Errorf("Next run time %v is before now, %v", t, now)
+			return
+		}
+		c.reschedule(e, t)
+	})
+	if err != nil {
+		return err
+	}
+	return notFound(id, found)
+}
+
+// entryByID returns the entry with the given id, or nil.
+func (c *Cron) entryByID(id int64) *Entry {
+	if id <= 0 {
+		// Not a real id, like the 0 returned along with an error.
+		return nil
+	}
+	for _, e := range c.entries {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// GetNextRun returns the next time the job with the given id will run, and
+// whether it was found. It is the next activation time of the entry's schedule
+// even if the entry is paused; see NextEffective.
+func (c *Cron) GetNextRun(id int64) (time.Time, bool) {
+	var (
+		next  time.Time
+		found bool
+	)
+	c.do(func() {
+		if e := c.entryByID(id); e != nil {
+			next, found = e.Next, true
+		}
+	})
+	return next, found
+}
+
+// NextEffective is like GetNextRun, but returns the zero time if the job won't
+// actually run then, as its entry or the whole Cron is paused.
+func (c *Cron) NextEffective(id int64) (time.Time, bool) {
+	var (
+		next  time.Time
+		found bool
+	)
+	c.do(func() {
+		if e := c.entryByID(id); e != nil {
+			found = true
+			if e.Status == StatusRunning && atomic.LoadInt32(&c.paused) == 0 {
+				next = e.Next
+			}
+		}
+	})
+	return next, found
+}
+
+// GetPrevRun returns the last time the job with the given id was run, and
+// whether it was found.
+func (c *Cron) GetPrevRun(id int64) (time.Time, bool) {
+	var (
+		prev  time.Time
+		found bool
+	)
+	c.do(func() {
+		if e := c.entryByID(id); e != nil {
+			prev, found = e.Prev, true
+		}
+	})
+	return prev, found
+}
+
+// TimeUntilNext returns how long it is, by the Cron's clock, until the job with
+// the given id runs next, or 0 if that time has passed. It reports false if
+// there is no such job or it has no next run time.
+func (c *Cron) TimeUntilNext(id int64) (time.Duration, bool) {
+	next, ok := c.GetNextRun(id)
+	if !ok || next.IsZero() {
+		return 0, false
+	}
+	if d := next.Sub(c.clock.Now()); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// IsSatisfiable reports whether the job with the given id has a next run
+// time, as opposed to a schedule that never activates again, like one for
+// February 30. Entries whose Next is yet to be computed, because the Cron is
+// not running, are checked against now. It returns an error wrapping
+// ErrNotFound if there is no such job.
+func (c *Cron) IsSatisfiable(id int64) (bool, error) {
+	var (
+		next  time.Time
+		found bool
+	)
+	c.do(func() {
+		e := c.entryByID(id)
+		if e == nil {
+			return
+		}
+		next, found = e.Next, true
+		if next.IsZero() && !c.IsRunning() {
+			next = e.Schedule.Next(c.clock.Now().Local())
+		}
+	})
+	return !next.IsZero(), notFound(id, found)
+}
+
+// NextWake returns the earliest next run time of all entries, which is when
+// the scheduler wakes up next to run jobs. It reports false if no entry has a
+// next run time, e.g. because the Cron is not running.
+func (c *Cron) NextWake() (time.Time, bool) {
+	var next time.Time
+	c.do(func() {
+		for _, e := range c.entries {
+			if !e.Next.IsZero() && (next.IsZero() || e.Next.Before(next)) {
+				next = e.Next
+			}
+		}
+	})
+	return next, !next.IsZero()
+}
+
+// DueWithin returns snapshots of the entries whose Next falls within d from
+// now, the next to run first. It goes by the Next of the entries as they are,
+// without advancing their schedules, so an entry due more than once in the
+// window is returned once.
+func (c *Cron) DueWithin(d time.Duration) []Entry {
+	var due []*Entry
+	c.do(func() {
+		now := c.clock.Now()
+		end := now.Add(d)
+		for _, e := range c.entries {
+			if !e.Next.IsZero() && !e.Next.Before(now) && !e.Next.After(end) {
+				due = append(due, e.snapshot())
+			}
+		}
+	})
+	sort.Stable(byTime(due))
+	entries := make([]Entry, len(due))
+	for i, e := range due {
+		entries[i] = *e
+	}
+	return entries
+}
+
+// ScheduleSummary returns the number of entries on each schedule, e.g. to spot
+// many jobs running at the same time. Schedules are named by their String if
+// they have one, like those of "@every" and sun specs, and otherwise by the
+// Spec they were parsed from, in lower case with single spaces; those added
+// without one are named by their type, e.g. "*cron.SpecSchedule".
+func (c *Cron) ScheduleSummary() map[string]int {
+	summary := map[string]int{}
+	c.do(func() {
+		for _, e := range c.entries {
+			summary[scheduleName(e)]++
+		}
+	})
+	return summary
+}
+
+// scheduleName names the schedule of the entry for ScheduleSummary.
+func scheduleName(e *Entry) string {
+	if s, ok := e.Schedule.(fmt.Stringer); ok {
+		return s.String()
+	}
+	if e.Spec != "" {
+		return canonicalSpec(e.Spec)
+	}
+	return fmt.Sprintf("%T", e.Schedule)
+}
+
+// HealthStatus is a summary of the state of a Cron, see Health.
+type HealthStatus struct {
+	// Whether the scheduler is running.
+	Running bool
+
+	// The number of entries.
+	Entries int
+
+	// The earliest next run time of all entries, as returned by NextWake, or
+	// the zero time if there is none.
+	NextWake time.Time
+
+	// The number of entries whose schedule is never activated again, see
+	// IsSatisfiable.
+	Unsatisfiable int
+
+	// The number of jobs running, counting each run of a job that is running
+	// more than once.
+	RunningJobs int
+}
+
+// Health returns a summary of the state of the Cron, taken at once, e.g. for a
+// health check endpoint. It is about as cheap as Len.
+func (c *Cron) Health() HealthStatus {
+	var status HealthStatus
+	c.do(func() {
+		running := c.IsRunning()
+		now := c.clock.Now().Local()
+		status = HealthStatus{Running: running, Entries: len(c.entries)}
+		for _, e := range c.entries {
+			next := e.Next
+			if next.IsZero() && !running {
+				next = e.Schedule.Next(now)
+			} else if !next.IsZero() && (status.NextWake.IsZero() || next.Before(status.NextWake)) {
+				status.NextWake = next
+			}
+			if next.IsZero() {
+				status.Unsatisfiable++
+			}
+		}
+		for _, n := range c.inFlight {
+			status.RunningJobs += n
+		}
+	})
+	return status
+}
+
+// Len returns the number of entries, without taking a snapshot.
+func (c *Cron) Len() int {
+	var n int
+	c.do(func() { n = len(c.entries) })
+	return n
+}
+
+// do runs fn with exclusive access to the entries: within the run loop when
+// running, or directly otherwise.
+func (c *Cron) do(fn func()) {
+	for !c.locked(fn) {
+		done := make(chan struct{})
+		select {
+		case c.exec <- func() {
+			fn()
+			close(done)
+		}:
+			<-done
+			return
+		case <-c.stopped():
+		}
+	}
+}
+
+// locked runs fn holding entriesMu if the Cron is not running, and reports
+// whether it did. Otherwise the caller should hand the work to the run loop,
+// and try again should it stop in the meantime.
+func (c *Cron) locked(fn func()) bool {
+	c.entriesMu.Lock()
+	defer c.entriesMu.Unlock()
+	if c.IsRunning() {
+		return false
+	}
+	fn()
+	return true
+}
+
+// Start the cron scheduler in its own go-routine. Calling Start on a Cron that
+// is already running has no effect. The scheduler stops when ctx is done, after
+// which the Cron may be started again.
+func (c *Cron) Start(ctx context.Context) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	if c.IsRunning() {
+		return
+	}
+	c.done = make(chan struct{})
+	// Wait for callers accessing the entries directly to finish.
+	c.entriesMu.Lock()
+	atomic.StoreInt32(&c.running, 1)
+	atomic.StoreInt32(&c.started, 1)
+	c.entriesMu.Unlock()
+	go c.run(ctx, c.done)
+}
+
+// Suspend stops the scheduler, keeping its entries, and returns once it has
+// stopped. Unlike cancelling the context given to Start, it leaves the jobs
+// already running and their context alone. Start resumes the entries, with
+// their next run times computed from then if they went by in the meantime.
+// Suspending a Cron that is not running has no effect.
+func (c *Cron) Suspend() {
+	done := c.stopped()
+	if done == nil {
+		return
+	}
+	select {
+	case c.suspend <- struct{}{}:
+		<-done
+	case <-done:
+	}
+}
+
+// Stop stops the scheduler like Suspend, and then waits for the jobs running to
+// return, including those started by earlier runs of the scheduler. Then it
+// calls the func set with OnStop for each entry before returning. Cancelling
+// the context given to Start doesn't call it.
+func (c *Cron) Stop() {
+	c.Suspend()
+	c.jobs.Wait()
+
+	c.hookMu.Lock()
+	onStop := c.onStop
+	c.hookMu.Unlock()
+	if onStop == nil {
+		return
+	}
+	var entries []*Entry
+	c.do(func() { entries = c.entrySnapshot() })
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	for _, e := range entries {
+		onStop(e)
+	}
+}
+
+// IsRunning reports whether the scheduler has been started, and not stopped
+// since.
+func (c *Cron) IsRunning() bool {
+	return atomic.LoadInt32(&c.running) == 1
+}
+
+// Started reports whether the scheduler has ever been started. Jobs added to a
+// Cron that never is don't run.
+func (c *Cron) Started() bool {
+	return atomic.LoadInt32(&c.started) == 1
+}
+
+// watchStart has a warning logged if entries were added and Start is not
+// called within the grace period set by WithStartWarning.
+func (c *Cron) watchStart() {
+	if c.startLogger == nil {
+		return
+	}
+	c.startOnce.Do(func() {
+		time.AfterFunc(c.startGrace, func() {
+			if c.Started() {
+				return
+			}
+			if n := c.Len(); n > 0 {
+				c.startLogger.Printf("cron: %d entries pending, scheduler not started", n)
+			}
 		})
+	})
+}
+
+// stopped returns a channel that is closed when the run loop returns, so that
+// callers waiting on it don't wait forever once it is gone.
+func (c *Cron) stopped() <-chan struct{} {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	return c.done
+}
+
+// maxSleep is the longest the scheduler sleeps before taking another look at
+// its entries, so that it never relies on a timer running for years.
+const maxSleep = 24 * time.Hour
+
+// Run the scheduler.. this is private just due to the need to synchronize
+// access to the 'running' state variable.
+func (c *Cron) run(ctx context.Context, done chan struct{}) {
+	defer func() {
+		c.runMu.Lock()
+		defer c.runMu.Unlock()
+		atomic.StoreInt32(&c.running, 0)
+		close(done)
+	}()
+
+	// Forget about the runs of an earlier run loop; it didn't wait for them.
+	c.loopDone = done
+	c.active, c.queue, c.inFlight = 0, nil, make(map[int64]int)
+	for _, e := range c.entries {
+		e.runCtx, e.cancelRun, e.overlapped = nil, nil, nil
+	}
+	c.idle = false
+
+	// Figure out the next activation times for the entries that don't have
+	// one yet, or whose one went by while the Cron was stopped. Those of
+	// schedules counting from their start are counted from now.
+	now := c.clock.Now().Local()
+	for _, entry := range c.entries {
+		if entry.Next.IsZero() {
+			c.catchUpSun(entry, now)
+		}
+		if entry.Next.IsZero() || entry.Next.Before(now) || fromStart(entry.Schedule) {
+			c.firstNext(entry, now)
+		}
+	}
+	heap.Init((*byTime)(&c.entries))
+
+	last := now
+	for {
+		if now.Before(last) {
+			c.clockSetBack(now)
+		}
+		last = now
+
+		if c.pendingNow {
+			c.startPending(ctx, now)
+		}
+
+		// Determine the next entry to run: the entries are kept as a heap by
+		// Next, so it is the first one.
+		var effective time.Time
+		if len(c.entries) == 0 || c.entries[0].Next.IsZero() {
+			// If there are no entries yet, just sleep - it still handles new entries
+			// and stop requests.
+			effective = now.AddDate(10, 0, 0)
+			if c.idleHorizon > 0 {
+				effective = now.Add(c.idleHorizon)
+			}
+			if !c.idle {
+				c.idle = true
+				c.publish(Event{Type: EventIdle, Time: now})
+			}
+		} else {
+			effective = c.entries[0].Next
+			if c.idle {
+				c.idle = false
+				c.publish(Event{Type: EventBusy, Time: now})
+			}
+		}
+
+		wait := effective.Sub(now)
+		if c.wakeInterval > 0 && wait > c.wakeInterval {
+			wait = c.wakeInterval
+		}
+		if wait > maxSleep {
+			wait = maxSleep
+		}
+
+		// In test mode only Tick runs the entries.
+		var timer <-chan time.Time
+		if !c.testMode {
+			timer = c.clock.After(wait)
+		}
+
+		select {
+		case now = <-timer:
+			if now.Before(effective) {
+				// Woken up early to take another look, e.g. at a clock that
+				// was set in the meantime.
+				continue
+			}
+			// Run every entry whose next time was this effective time, taking
+			// them off the heap until its first entry is due later. Compare
+			// with Equal: Next may carry a different location or a monotonic
+			// reading than effective while denoting the same instant.
+			var due []*Entry
+			for len(c.entries) > 0 && c.entries[0].Next.Equal(effective) {
+				due = append(due, heap.Pop((*byTime)(&c.entries)).(*Entry))
+			}
+			if c.fair && len(due) > 1 {
+				// Start from a different entry each time, going by id, so that
+				// the same ones don't always wait for a free slot.
+				sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+				i := c.turn % len(due)
+				due = append(due[i:len(due):len(due)], due[:i]...)
+				c.turn++
+			}
+			// Synchronous first runs go before the rest, which may depend on
+			// them, and the others by priority.
+			sort.SliceStable(due, func(i, j int) bool {
+				if due[i].syncRun != due[j].syncRun {
+					return due[i].syncRun
+				}
+				return due[i].Priority > due[j].Priority
+			})
+			for _, e := range due {
+				// Waking up after the following activation time as well, e.g.
+				// after the process was suspended, means runs were missed. They
+				// are dropped, and the run for effective is too unless catching
+				// up.
+				next, nextIsProbe := c.next(e, effective)
+				missed := !next.IsZero() && !next.After(now) && !nextIsProbe
+				if missed {
+					next, nextIsProbe = c.next(e, now)
+				}
+				// Prev only moves for runs that happen. Next moves on from
+				// the schedule either way, not from when a job returns.
+				switch {
+				case e.probe:
+					// Only the gate was checked, see GatedBy.
+				case e.Status == StatusRunning && atomic.LoadInt32(&c.paused) == 0 && (!missed || c.catchUp):
+					if c.dispatch(ctx, e, effective) {
+						e.Prev = e.Next
+					}
+				default:
+					c.publish(Event{ID: e.ID, Type: EventJobSkipped, Time: effective})
+				}
+				e.Next, e.probe = next, nextIsProbe
+				if !e.inlineNext.IsZero() {
+					e.Next, e.probe, e.inlineNext = e.inlineNext, false, time.Time{}
+				}
+			}
+			c.push(due...)
+			continue
+
+		case req := <-c.add:
+			c.addEntry(req, now)
+			// Take the rest of a burst of adds in one go, rather than going
+			// round the loop for each.
+			c.takeAdds(now)
+
+		case entries := <-c.replace:
+			c.takeAdds(now)
+			for _, e := range entries {
+				c.firstNext(e, now)
+			}
+			c.replaceEntries(entries)
+			heap.Init((*byTime)(&c.entries))
+
+		// The adds waiting in the buffer, see WithAddBuffer, were made before
+		// the calls below and Tick, so they are taken first.
+		case r := <-c.remove:
+			c.takeAdds(now)
+			r.found <- c.removeJob(r.id)
+		case reply := <-c.removeAll:
+			c.takeAdds(now)
+			reply <- c.removeAllJobs()
+		case <-c.snapshot:
+			c.takeAdds(now)
+			c.snapshot <- c.entrySnapshot()
+
+		case fn := <-c.exec:
+			c.takeAdds(now)
+			fn()
+
+		case result := <-c.finished:
+			c.finish(ctx, result)
+
+		case t := <-c.tick:
+			c.takeAdds(now)
+			t.ran <- c.runDue(ctx, t.to)
+
+		case <-c.suspend:
+			return
+
+		case <-ctx.Done():
+			return
+		}
+
+		// 'now' should be updated after newEntry and snapshot cases.
+		now = c.clock.Now().Local()
+	}
+}
+
+// Tick runs the jobs of the entries due by advanceTo, one after the other, in
+// the order they are due, and returns the ids of those it ran. An entry due
+// several times by then is run as many times. The Cron's clock is not moved.
+//
+// Tick is a testing aid for a Cron created WithTestMode, and must be called
+// while it is running. Otherwise it returns an error, wrapping ErrNotRunning if
+// the Cron is stopped.
+func (c *Cron) Tick(advanceTo time.Time) ([]int64, error) {
+	if !c.testMode {
+		return nil, errors.New("Tick needs a Cron created WithTestMode")
+	}
+	if !c.IsRunning() {
+		return nil, ErrNotRunning
+	}
+	t := tick{advanceTo, make(chan []int64)}
+	select {
+	case c.tick <- t:
+		return <-t.ran, nil
+	case <-c.stopped():
+		return nil, ErrNotRunning
+	}
+}
+
+// runDue runs the jobs of the entries due by to, see Tick.
+func (c *Cron) runDue(ctx context.Context, to time.Time) []int64 {
+	var ran []int64
+	for len(c.entries) > 0 && !c.entries[0].Next.IsZero() && !c.entries[0].Next.After(to) {
+		e := c.entries[0]
+		due, probe := e.Next, e.probe
+		e.Next, e.probe = c.next(e, due)
+		heap.Fix((*byTime)(&c.entries), 0)
+		if probe {
+			continue
+		}
+		if e.Status != StatusRunning || atomic.LoadInt32(&c.paused) != 0 {
+			c.publish(Event{ID: e.ID, Type: EventJobSkipped, Time: due})
+			continue
+		}
+		if c.dispatch(ctx, e, due) {
+			e.Prev = due
+			ran = append(ran, e.ID)
+		}
+	}
+	return ran
+}
+
+// addEntry schedules an entry added while running, replying with a snapshot
+// of it if asked to.
+func (c *Cron) addEntry(req addition, now time.Time) {
+	e := req.entry
+	c.catchUpSun(e, now)
+	c.firstNext(e, now)
+	c.push(e)
+	c.pendingNow = c.pendingNow || e.runNow
+	if req.added != nil {
+		req.added <- *e.snapshot()
+	}
+}
+
+// takeAdds schedules the entries waiting in the buffer of adds, if any.
+func (c *Cron) takeAdds(now time.Time) {
+	for {
+		select {
+		case req := <-c.add:
+			c.addEntry(req, now)
+		default:
+			return
+		}
+	}
+}
+
+// finish records the outcome of a run of a job.
+func (c *Cron) finish(ctx context.Context, result jobResult) {
+	c.active--
+	c.startQueued(ctx)
+	e := result.entry
+	if c.inFlight[e.ID]--; c.inFlight[e.ID] == 0 {
+		delete(c.inFlight, e.ID)
+	}
+	e.finishedCount++
+	e.LastDuration = result.end.Sub(result.start)
+	e.LastLatency = result.start.Sub(result.due)
+	e.AvgDuration += (e.LastDuration - e.AvgDuration) / time.Duration(e.finishedCount)
+	event := Event{ID: e.ID, Type: EventJobFinished, Time: result.end, Duration: e.LastDuration, RunID: result.runID}
+	if result.err != nil {
+		e.LastError, e.LastErrorTime = result.err, result.end
+		event.Type, event.Err = EventJobErrored, result.err
+	} else {
+		e.LastError, e.LastErrorTime = nil, time.Time{}
+	}
+	c.publish(event)
+	c.checkOverrun(result)
+	if !result.next.IsZero() && !c.reschedule(e, result.next) {
+		// Run inline, the entry may be off the heap; the run loop puts it
+		// back with this time.
+		e.inlineNext = result.next
+	}
+	if len(e.overlapped) > 0 && c.inFlight[e.ID] == 0 && c.entryByID(e.ID) == e {
+		due := e.overlapped[0]
+		e.overlapped = e.overlapped[1:]
+		c.dispatch(ctx, e, due)
+	}
+}
+
+// firstNext sets the entry's Next to the first activation time of its schedule
+// after now, when the Cron is started or the entry added to a running Cron. If
+// that starts an @reboot schedule of the entry, its job is run right away, as
+// with WithRunNow.
+func (c *Cron) firstNext(e *Entry, now time.Time) {
+	if startReboot(e.Schedule) {
+		e.runNow = true
+		c.pendingNow = true
+	}
+	e.Next, e.probe = nextProbe(e.Schedule, now)
+}
+
+// next returns the next activation time of the entry's schedule after t, the
+// time it last ran, and whether it is a probe. A time not after t, which a
+// buggy Schedule may return, would have the run loop run the entry over and
+// over without sleeping, so it is logged and the zero time returned instead:
+// the entry is not run again.
+func (c *Cron) next(e *Entry, t time.Time) (time.Time, bool) {
+	next, probe := nextProbe(e.Schedule, t)
+	if !next.IsZero() && !next.After(t) {
+		c.logger.Printf("cron: schedule of entry %d returned %v, not after %v; not running it again", e.ID, next, t)
+		return time.Time{}, false
+	}
+	return next, probe
+}
+
+// checkOverrun publishes EventJobOverran if the run took longer than the
+// threshold share of the interval from its due time to the following one.
+func (c *Cron) checkOverrun(result jobResult) {
+	if atomic.LoadInt32(&c.subscribed) == 0 {
+		return
+	}
+	e := result.entry
+	next := e.Schedule.Next(result.due)
+	if next.IsZero() {
+		return
+	}
+	interval := next.Sub(result.due)
+	if float64(e.LastDuration) > c.overrunThreshold*float64(interval) {
+		c.publish(Event{ID: e.ID, Type: EventJobOverran, Time: result.end, Duration: e.LastDuration, Interval: interval, RunID: result.runID})
+	}
+}
+
+// reschedule sets the next run time of the entry, unless it was removed or is
+// off the heap to be run, reporting whether it did.
+func (c *Cron) reschedule(e *Entry, at time.Time) bool {
+	for i, x := range c.entries {
+		if x == e {
+			e.Next, e.probe = at, false
+			heap.Fix((*byTime)(&c.entries), i)
+			return true
+		}
+	}
+	return false
+}
+
+// clockSetBack moves up the next run times of the entries that their schedule
+// gives an earlier one for from now, after the clock was set back: they were
+// computed from the later time, and the scheduler would wait for them for as
+// long as it was set back. So the runs of the time the clock was set back over
+// happen again, as the clock reads.
+func (c *Cron) clockSetBack(now time.Time) {
+	for _, e := range c.entries {
+		if next, probe := nextProbe(e.Schedule, now); !next.IsZero() && next.Before(e.Next) {
+			e.Next, e.probe = next, probe
+		}
+	}
+	heap.Init((*byTime)(&c.entries))
+}
+
+// catchUpSun has the job of an entry just scheduled run right away with
+// WithCatchUp, if it is on a sun schedule whose event of the day went by
+// already. Otherwise e.g. lights meant to go on at sunset would stay off until
+// the next day's.
+func (c *Cron) catchUpSun(e *Entry, now time.Time) {
+	if c.catchUp && !passedSunEvent(e.Schedule, now).IsZero() {
+		e.runNow = true
+		c.pendingNow = true
+	}
+}
+
+// startPending runs the jobs of the entries added WithRunNow that haven't been
+// run yet, unless they or the Cron are paused.
+func (c *Cron) startPending(ctx context.Context, now time.Time) {
+	c.pendingNow = false
+	for _, e := range c.entries {
+		if !e.runNow {
+			continue
+		}
+		e.runNow = false
+		if e.Status == StatusRunning && atomic.LoadInt32(&c.paused) == 0 && c.dispatch(ctx, e, now) {
+			e.Prev = now
+		}
+	}
+}
+
+// dispatch starts the entry's job, due at the given time, or queues the run if
+// the limit on jobs running at once is reached. In a dry run, it is logged
+// instead. It reports false if the run is skipped, as its job is still running.
+func (c *Cron) dispatch(ctx context.Context, e *Entry, due time.Time) bool {
+	if c.dryRun != nil {
+		c.dryRun.Printf("cron: dry run: entry %d due at %v", e.ID, due)
+		return true
 	}
+	if c.inFlight[e.ID] > 0 {
+		switch c.overlap {
+		case OverlapSkip:
+			c.publish(Event{ID: e.ID, Type: EventJobSkipped, Time: due})
+			return false
+		case OverlapQueue:
+			e.overlapped = append(e.overlapped, due)
+			return true
+		}
+	}
+	if c.maxConcurrency > 0 && c.active >= c.maxConcurrency {
+		c.queue = append(c.queue, queuedRun{e, due})
+		return true
+	}
+	c.startJob(ctx, e, due)
+	return true
+}
+
+// startQueued starts the queued runs that fit within the limit on jobs running
+// at once, in the order they were queued. Runs of removed entries are dropped.
+func (c *Cron) startQueued(ctx context.Context) {
+	for len(c.queue) > 0 && c.active < c.maxConcurrency {
+		run := c.queue[0]
+		c.queue = c.queue[1:]
+		if c.entryByID(run.entry.ID) == run.entry {
+			c.startJob(ctx, run.entry, run.due)
+		}
+	}
+}
+
+// startJob runs the entry's job, due at the given time, in its own goroutine,
+// and reports the outcome back to the run loop. A synchronous first run, or
+// any run in test mode or with inline execution, is done right away instead.
+func (c *Cron) startJob(ctx context.Context, e *Entry, due time.Time) {
+	e.RunCount++
+	c.active++
+	c.inFlight[e.ID]++
+	c.lastRunID++
+	runID := c.lastRunID
+	c.publish(Event{ID: e.ID, Type: EventJobStarted, Time: due, RunID: runID})
+	job := e.Job
+	entry := e.snapshot()
+	if c.cancelOnRemove {
+		if e.runCtx == nil {
+			e.runCtx, e.cancelRun = context.WithCancel(ctx)
+		}
+		ctx = e.runCtx
+	}
+	c.hookMu.Lock()
+	onStart, onEnd := c.onJobStart, c.onJobEnd
+	c.hookMu.Unlock()
+	runLogger := c.runLogger
+	run := func() jobResult {
+		if onStart != nil {
+			onStart(entry)
+		}
+		if runLogger != nil {
+			runLogger.Printf("cron: run %d of entry %d started", runID, entry.ID)
+		}
+		start := c.clock.Now()
+		next := &rescheduled{}
+		runCtx := context.WithValue(context.WithValue(ctx, entryKey{}, entry), rescheduleKey{}, next)
+		runCtx = context.WithValue(runCtx, runIDKey{}, runID)
+		err := RunJob(runCtx, job)
+		end := c.clock.Now()
+		if onEnd != nil {
+			onEnd(entry, end.Sub(start))
+		}
+		if runLogger != nil {
+			if err != nil {
+				runLogger.Printf("cron: run %d of entry %d failed after %v: %v", runID, entry.ID, end.Sub(start), err)
+			} else {
+				runLogger.Printf("cron: run %d of entry %d finished after %v", runID, entry.ID, end.Sub(start))
+			}
+		}
+		return jobResult{entry: e, err: err, due: due, start: start, end: end, next: next.get(), runID: runID}
+	}
+
+	if e.syncRun || c.testMode || c.inline {
+		e.syncRun = false
+		c.finish(ctx, run())
+		return
+	}
+	done := c.loopDone
+	c.jobs.Add(1)
+	go func() {
+		defer c.jobs.Done()
+		result := run()
+		select {
+		case c.finished <- result:
+		case <-ctx.Done():
+		case <-done:
+		}
+	}()
+}
+
+// entrySnapshot returns a copy of the current cron entry list, in the order
+// they will run: by Next, with zero times at the end. It is only called with
+// exclusive access to the entries, in the run loop or holding entriesMu, so
+// the copies are all taken at the same point in time.
+func (c *Cron) entrySnapshot() []*Entry {
+	entries := []*Entry{}
+	for _, e := range c.entries {
+		entries = append(entries, e.snapshot())
+	}
+	sort.Stable(byTime(entries))
 	return entries
 }
+
+// copyLabels returns a copy of the labels, or nil if there are none.
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
+
+// snapshot returns a copy of the entry.
+func (e *Entry) snapshot() *Entry {
+	return &Entry{
+		Schedule: e.Schedule,
+		Next:     e.Next,
+		Prev:     e.Prev,
+		Job:      e.Job,
+		ID:       e.ID,
+		Name:     e.Name,
+		Spec:     e.Spec,
+		Labels:   copyLabels(e.Labels),
+		Meta:     e.Meta,
+		Priority: e.Priority,
+		GroupID:  e.GroupID,
+		Status:   e.Status,
+		RunCount: e.RunCount,
+
+		LastError:     e.LastError,
+		LastErrorTime: e.LastErrorTime,
+		LastDuration:  e.LastDuration,
+		AvgDuration:   e.AvgDuration,
+		LastLatency:   e.LastLatency,
+		finishedCount: e.finishedCount,
+		cmd:           e.cmd,
+		onPanic:       e.onPanic,
+	}
+}
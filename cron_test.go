@@ -1,9 +1,18 @@
 package cron
 
 import (
+	"container/heap"
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -45,6 +54,28 @@ func TestStopCausesJobsToNotRun(t *testing.T) {
 	}
 }
 
+// Test that Entries doesn't hang when called as the run loop is stopping.
+func TestEntriesAfterCancel(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		cron := New()
+		cron.AddFunc("* * * * * ?", func() {})
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+		cancel()
+
+		entries := make(chan []*Entry)
+		go func() { entries <- cron.Entries() }()
+		select {
+		case e := <-entries:
+			if len(e) != 1 {
+				t.Fatalf("expected 1 entry, got %d", len(e))
+			}
+		case <-time.After(ONE_SECOND):
+			t.Fatal("expected Entries to return")
+		}
+	}
+}
+
 // Add a job, start cron, expect it runs.
 func TestAddBeforeRunning(t *testing.T) {
 	wg := &sync.WaitGroup{}
@@ -108,6 +139,74 @@ func TestSnapshotEntries(t *testing.T) {
 
 }
 
+// Test that consecutive runs of an @every 1s job land about a second apart.
+func TestEverySecondInterval(t *testing.T) {
+	runs := make(chan time.Time, 10)
+	cron := New()
+	cron.AddFunc("@every 1s", func() { runs <- time.Now() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	var last time.Time
+	for i := 0; i < 4; i++ {
+		select {
+		case at := <-runs:
+			// The first run is aligned to the second, so it may come sooner.
+			if i > 0 {
+				if d := at.Sub(last); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+					t.Errorf("run %d came %v after the one before", i, d)
+				}
+			}
+			last = at
+		case <-time.After(2 * ONE_SECOND):
+			t.Fatalf("run %d did not happen", i)
+		}
+	}
+}
+
+// Test that a sub-second schedule isn't rounded to seconds by the run loop.
+func TestSubSecondSchedule(t *testing.T) {
+	var runs int64
+	cron := New()
+	cron.AddFunc("@every 250ms", func() { atomic.AddInt64(&runs, 1) })
+	ctx, cancel := context.WithCancel(context.Background())
+	cron.Start(ctx)
+	time.Sleep(ONE_SECOND)
+	cancel()
+
+	if n := atomic.LoadInt64(&runs); n < 3 || n > 5 {
+		t.Errorf("expected about 4 runs in a second, got %d", n)
+	}
+}
+
+// Test that Entries returns the next entry to run first.
+func TestEntriesOrder(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	cron.AddFunc("0 0 0 30 2 ?", func() {})
+	cron.AddFunc("0 0 15 * * ?", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	cron.AddFunc("0 0 16 * * ?", func() {})
+	cron.AddFunc("0 30 14 * * ?", func() {})
+
+	entries := cron.Entries()
+	if expected := getTime("Mon Jul 9 14:30 2012").Local(); !entries[0].Next.Equal(expected) {
+		t.Errorf("expected the first entry to run at %v, got %v", expected, entries[0].Next)
+	}
+	for i := 1; i < len(entries)-1; i++ {
+		if entries[i].Next.Before(entries[i-1].Next) {
+			t.Errorf("entry %d runs before entry %d", i, i-1)
+		}
+	}
+	if last := entries[len(entries)-1]; !last.Next.IsZero() {
+		t.Errorf("expected the unsatisfiable entry last, got next %v", last.Next)
+	}
+}
+
 // Test that the entries are correctly sorted.
 // Add a bunch of long-in-the-future entries, and an immediate entry, and ensure
 // that the immediate entry runs immediately.
@@ -199,68 +298,2868 @@ func TestLocalTimezone(t *testing.T) {
 	}
 }
 
-type testJob struct {
-	wg   *sync.WaitGroup
-	name string
+// Drive the scheduler with a fake clock and check exactly which jobs fired.
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	everySecond := make(chan struct{}, 10)
+	everyMinute := make(chan struct{}, 10)
+
+	cron := New(WithClock(clock))
+	cron.AddFunc("* * * * * ?", func() { everySecond <- struct{}{} })
+	cron.AddFunc("0 * * * * ?", func() { everyMinute <- struct{}{} })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		select {
+		case <-everySecond:
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("tick %d: every-second job did not run", i)
+		}
+	}
+
+	select {
+	case <-everyMinute:
+		t.Error("every-minute job should not have run")
+	case <-time.After(10 * time.Millisecond):
+	}
 }
 
-func (t testJob) Run() {
-	t.wg.Done()
+// utcSchedule reports the activation times of its schedule in UTC.
+type utcSchedule struct{ Schedule }
+
+func (s utcSchedule) Next(t time.Time) time.Time { return s.Schedule.Next(t).UTC() }
+
+// evenHours is a Schedule activated at the start of every even hour.
+type evenHours struct{}
+
+func (evenHours) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Hour).Add(time.Hour)
+	if next.Hour()%2 == 1 {
+		next = next.Add(time.Hour)
+	}
+	return next
 }
 
-// Simple test using Runnables.
-func TestJob(t *testing.T) {
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
+// backwards is a buggy Schedule whose next activation time is before the time
+// it is given.
+type backwards struct{}
+
+func (backwards) Next(t time.Time) time.Time { return t.Add(-time.Minute) }
+
+// Test that an entry whose schedule goes back in time is run once rather than
+// over and over, with the problem logged.
+func TestScheduleGoingBack(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	lines := make(logLines, 10)
+	cron := New(WithClock(clock), WithLogger(log.New(lines, "", 0)))
+	var runs int32
+	id := cron.AddSchedule(backwards{}, FuncJob(func() { atomic.AddInt32(&runs, 1) }))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	select {
+	case line := <-lines:
+		if !strings.Contains(line, fmt.Sprintf("schedule of entry %d", id)) {
+			t.Errorf("unexpected log line %q", line)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the problem to be logged")
+	}
+	waitForEntry(t, cron, id, func(e Entry) bool { return e.Next.IsZero() })
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("(expected) 1 != %d (actual) runs", n)
+	}
+}
+
+func TestNextIterator(t *testing.T) {
+	schedule, _ := Parse("0 30 9 * * *")
+	next := NextIterator(schedule, getTime("Mon Jul 9 14:00 2012").Local())
+	for _, expected := range []string{
+		"Tue Jul 10 09:30 2012",
+		"Wed Jul 11 09:30 2012",
+		"Thu Jul 12 09:30 2012",
+		"Fri Jul 13 09:30 2012",
+	} {
+		if actual := next(); !actual.Equal(getTime(expected).Local()) {
+			t.Errorf("(expected) %v != %v (actual)", expected, actual)
+		}
+	}
 
+	never, _ := Parse("0 0 0 30 2 *")
+	next = NextIterator(never, getTime("Mon Jul 9 14:00 2012").Local())
+	if actual := next(); !actual.IsZero() {
+		t.Errorf("expected the zero time for an unsatisfiable schedule, got %v", actual)
+	}
+	next = NextIterator(backwards{}, getTime("Mon Jul 9 14:00 2012").Local())
+	if first, second := next(), next(); !first.IsZero() || !second.IsZero() {
+		t.Errorf("expected the zero time for a schedule going back, got %v, %v", first, second)
+	}
+}
+
+func TestNilJob(t *testing.T) {
+	var nilJob *testJob
 	cron := New()
-	cron.AddJob("0 0 0 30 Feb ?", testJob{wg, "job0"})
-	cron.AddJob("0 0 0 1 1 ?", testJob{wg, "job1"})
-	cron.AddJob("* * * * * ?", testJob{wg, "job2"})
-	cron.AddJob("1 0 0 1 1 ?", testJob{wg, "job3"})
-	cron.Schedule(Every(5*time.Second+5*time.Nanosecond), testJob{wg, "job4"}, 1)
-	cron.Schedule(Every(5*time.Minute), testJob{wg, "job5"}, 2)
+	if _, err := cron.AddFunc("* * * * * ?", nil); !errors.Is(err, ErrNilJob) {
+		t.Errorf("AddFunc: expected ErrNilJob, got %v", err)
+	}
+	if _, err := cron.AddJob("* * * * * ?", nil); !errors.Is(err, ErrNilJob) {
+		t.Errorf("AddJob: expected ErrNilJob, got %v", err)
+	}
+	if _, err := cron.AddJob("* * * * * ?", nilJob); !errors.Is(err, ErrNilJob) {
+		t.Errorf("AddJob with a nil pointer: expected ErrNilJob, got %v", err)
+	}
+	if err := cron.ScheduleContext(context.Background(), Every(time.Second), nil, 1); !errors.Is(err, ErrNilJob) {
+		t.Errorf("ScheduleContext: expected ErrNilJob, got %v", err)
+	}
+	if err := cron.AddFuncNamed("nightly", "* * * * * ?", nil); !errors.Is(err, ErrNilJob) {
+		t.Errorf("AddFuncNamed: expected ErrNilJob, got %v", err)
+	}
+	if _, err := cron.BatchAdd([]string{"* * * * * ?"}, []Job{nil}); !errors.Is(err, ErrNilJob) {
+		t.Errorf("BatchAdd: expected ErrNilJob, got %v", err)
+	}
+	if id := cron.AddSchedule(Every(time.Second), nil); id != 0 {
+		t.Errorf("AddSchedule: expected id 0, got %d", id)
+	}
+	if n := cron.Len(); n != 0 {
+		t.Errorf("expected no entries, got %d", n)
+	}
+}
+
+func TestAddSchedule(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:30 2012").Local())
+	cron := New(WithClock(clock))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	id := cron.AddSchedule(evenHours{}, FuncJob(func() {}))
+	other, _ := cron.AddFunc("0 0 0 1 1 ?", func() {})
+	if id <= 0 || other <= id {
+		t.Errorf("expected ids to be assigned in order, got %d and %d", id, other)
+	}
+	expected := getTime("Mon Jul 9 16:00 2012").Local()
+	if entry, ok := cron.EntryByID(id); !ok || !entry.Next.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, entry.Next)
+	}
+}
+
+// Test that entries due at the same instant run in the same tick, even when
+// their times are expressed in different locations.
+func TestSameInstantDifferentLocation(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan int64, 10)
+
+	cron := New(WithClock(clock))
+	cron.Schedule(Every(time.Second), FuncJob(func() { ran <- 1 }), 1)
+	cron.Schedule(utcSchedule{Every(time.Second)}, FuncJob(func() { ran <- 2 }), 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	seen := map[int64]bool{}
+	for len(seen) < 2 {
+		select {
+		case id := <-ran:
+			seen[id] = true
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("expected both jobs to run, got %v", seen)
+		}
+	}
+}
+
+// Test that three jobs due at once all run in the same tick.
+func TestThreeJobsDueAtOnce(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan int64, 10)
+
+	cron := New(WithClock(clock))
+	cron.AddFunc("0 0 0 1 1 ?", func() { ran <- 0 })
+	for i := int64(1); i <= 3; i++ {
+		id := i
+		cron.Schedule(Every(time.Minute), FuncJob(func() { ran <- id }), id)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	seen := map[int64]bool{}
+	for len(seen) < 3 {
+		select {
+		case id := <-ran:
+			if id == 0 {
+				t.Fatal("yearly job should not have run")
+			}
+			seen[id] = true
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("expected all three jobs to run, got %v", seen)
+		}
+	}
+}
 
+// Test that no job runs while the scheduler is paused, and that jobs run again
+// once it is resumed.
+func TestPauseAll(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan struct{}, 10)
+
+	cron := New(WithClock(clock))
+	cron.AddFunc("* * * * * ?", func() { ran <- struct{}{} })
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	cron.Start(ctx)
 
+	cron.PauseAll()
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	clock.BlockUntil(1)
+	select {
+	case <-ran:
+		t.Fatal("job ran while paused")
+	default:
+	}
+
+	cron.ResumeAll()
+	clock.Advance(time.Second)
 	select {
+	case <-ran:
 	case <-time.After(ONE_SECOND):
-		t.FailNow()
-	case <-wait(wg):
+		t.Fatal("job did not run after resume")
 	}
+}
 
-	// Ensure the entries are in the right order.
-	expecteds := []string{"job2", "job4", "job5", "job1", "job3", "job0"}
+func TestStatus(t *testing.T) {
+	cron := New()
+	id, _ := cron.AddFunc("* * * * * ?", func() {})
 
-	var actuals []string
-	for _, entry := range cron.Entries() {
-		actuals = append(actuals, entry.Job.(testJob).name)
+	if status := cron.Status(int(id)); status != StatusRunning {
+		t.Errorf("(expected) %d != %d (actual)", StatusRunning, status)
 	}
+	cron.PauseFunc(id)
+	if status := cron.Status(int(id)); status != StatusPaused {
+		t.Errorf("(expected) %d != %d (actual)", StatusPaused, status)
+	}
+	cron.ResumeFunc(id)
+	if status := cron.Status(int(id)); status != StatusRunning {
+		t.Errorf("(expected) %d != %d (actual)", StatusRunning, status)
+	}
+	if status := cron.Status(int(id) + 1); status != StatusUnknown {
+		t.Errorf("(expected) %d != %d (actual)", StatusUnknown, status)
+	}
+}
 
-	for i, expected := range expecteds {
-		if actuals[i] != expected {
-			t.Errorf("Jobs not in the right order.  (expected) %s != %s (actual)", expecteds, actuals)
-			t.FailNow()
+func TestRemoveAll(t *testing.T) {
+	cron := New()
+	for i := 0; i < 3; i++ {
+		cron.AddFunc("* * * * * ?", func() {})
+	}
+	if n := cron.RemoveAll(); n != 3 {
+		t.Errorf("(expected) 3 != %d (actual)", n)
+	}
+	if entries := cron.Entries(); len(entries) != 0 {
+		t.Errorf("expected no entries, got %d", len(entries))
+	}
+}
+
+func TestRemoveAllWhileRunning(t *testing.T) {
+	cron := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	for i := 0; i < 3; i++ {
+		cron.AddFunc("0 0 0 1 1 ?", func() {})
+	}
+	if n := cron.RemoveAll(); n != 3 {
+		t.Errorf("(expected) 3 != %d (actual)", n)
+	}
+	if entries := cron.Entries(); len(entries) != 0 {
+		t.Errorf("expected no entries, got %d", len(entries))
+	}
+}
+
+// Test that the context variants give up while the run loop is busy.
+func TestContextAddRemove(t *testing.T) {
+	cron := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	id, _ := cron.AddFunc("0 0 0 1 1 ?", func() {})
+
+	// Keep the run loop busy until released.
+	release, busy := make(chan struct{}), make(chan struct{})
+	go cron.do(func() {
+		close(busy)
+		<-release
+	})
+	<-busy
+
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelTimeout()
+	if _, err := cron.AddJobContext(timeout, "0 0 0 1 1 ?", FuncJob(func() {})); err != context.DeadlineExceeded {
+		t.Errorf("AddJobContext: expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if err := cron.RemoveJobContext(timeout, id); err != context.DeadlineExceeded {
+		t.Errorf("RemoveJobContext: expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if _, err := cron.RemoveAllContext(timeout); err != context.DeadlineExceeded {
+		t.Errorf("RemoveAllContext: expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	close(release)
+
+	if n := cron.Len(); n != 1 {
+		t.Errorf("expected the timed out calls to leave 1 entry, got %d", n)
+	}
+	if err := cron.RemoveJobContext(context.Background(), id); err != nil {
+		t.Error(err)
+	}
+	if n := cron.Len(); n != 0 {
+		t.Errorf("expected no entries, got %d", n)
+	}
+}
+
+// Test that the replaced entries run and the old ones don't.
+func TestReplaceAll(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan int64, 10)
+
+	cron := New(WithClock(clock))
+	cron.AddFunc("* * * * * ?", func() { ran <- 1 })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	cron.ReplaceAll([]*Entry{
+		{Schedule: Every(time.Second), Job: FuncJob(func() { ran <- 10 }), ID: 10},
+		{Schedule: Every(time.Second), Job: FuncJob(func() { ran <- 20 }), ID: 20},
+	})
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	seen := map[int64]bool{}
+	for len(seen) < 2 {
+		select {
+		case id := <-ran:
+			if id == 1 {
+				t.Fatal("replaced job ran")
+			}
+			seen[id] = true
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("expected the new jobs to run, got %v", seen)
 		}
 	}
+
+	var ids []int64
+	for _, e := range cron.Entries() {
+		ids = append(ids, e.ID)
+	}
+	if len(ids) != 2 {
+		t.Errorf("expected two entries, got ids %v", ids)
+	}
+	if id, _ := cron.AddFunc("* * * * * ?", func() {}); id <= 20 {
+		t.Errorf("new id %d collides with the replaced ids", id)
+	}
 }
 
-func wait(wg *sync.WaitGroup) chan bool {
-	ch := make(chan bool)
-	go func() {
-		wg.Wait()
-		ch <- true
-	}()
-	return ch
+// Test that ReplaceAll decorates the jobs of entries built by the caller with
+// the Cron's chain, and those of snapshots only once.
+func TestReplaceAllChain(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	wrapped := make(chan struct{}, 10)
+	cron := New(WithClock(clock), WithChain(func(j Job) Job {
+		return FuncJob(func() {
+			wrapped <- struct{}{}
+			j.Run()
+		})
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	cron.ReplaceAll([]*Entry{{Schedule: Every(time.Second), Job: FuncJob(func() {}), ID: 1}})
+
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		select {
+		case <-wrapped:
+		case <-time.After(ONE_SECOND):
+			t.Fatal("expected the job to be wrapped")
+		}
+		waitForEntry(t, cron, 1, func(e Entry) bool { return e.finishedCount == 1 })
+		select {
+		case <-wrapped:
+			t.Fatal("expected the job to be wrapped once")
+		default:
+		}
+		cron.ReplaceAll(cron.Entries())
+	}
 }
 
-func stop(cancel context.CancelFunc) chan bool {
-	ch := make(chan bool)
-	go func() {
+// Test that ReplaceAll rejects invalid entries, keeping the current ones.
+func TestReplaceAllInvalid(t *testing.T) {
+	cron := New()
+	id, _ := cron.AddFunc("@hourly", func() {})
+	job := FuncJob(func() {})
+	for _, c := range []struct {
+		name    string
+		entries []*Entry
+		is      error
+	}{
+		{"nil job", []*Entry{{Schedule: Every(time.Second), ID: 10}}, ErrNilJob},
+		{"nil func", []*Entry{{Schedule: Every(time.Second), Job: FuncJob(nil), ID: 10}}, ErrNilJob},
+		{"nil schedule", []*Entry{{Job: job, ID: 10}}, nil},
+		{"duplicate id", []*Entry{{Schedule: Every(time.Second), Job: job, ID: 10}, {Schedule: Every(time.Second), Job: job, ID: 10}}, ErrDuplicate},
+		{"zero id", []*Entry{{Schedule: Every(time.Second), Job: job}}, nil},
+		{"negative id", []*Entry{{Schedule: Every(time.Second), Job: job, ID: -1}}, nil},
+	} {
+		err := cron.ReplaceAll(c.entries)
+		if err == nil || c.is != nil && !errors.Is(err, c.is) {
+			t.Errorf("%s: expected an error wrapping %v, got %v", c.name, c.is, err)
+		}
+		if entries := cron.Entries(); len(entries) != 1 || entries[0].ID != id {
+			t.Errorf("%s: expected the entries to be left alone, got %v", c.name, entries)
+		}
+	}
+}
+
+func TestLen(t *testing.T) {
+	cron := New()
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	if n := cron.Len(); n != 2 {
+		t.Errorf("(expected) 2 != %d (actual)", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	if n := cron.Len(); n != 3 {
+		t.Errorf("(expected) 3 != %d (actual)", n)
+	}
+}
+
+func TestAddJobEntry(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	entry, err := cron.AddFuncEntry("0 30 * * * ?", func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID <= 0 || !entry.Next.IsZero() {
+		t.Errorf("before start: got id %d and next %v", entry.ID, entry.Next)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	entry, err = cron.AddFuncEntry("0 30 * * * ?", func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := getTime("Mon Jul 9 14:30 2012").Local(); !entry.Next.Equal(expected) {
+		t.Errorf("expected next %v, got %v", expected, entry.Next)
+	}
+
+	if _, err := cron.AddFuncEntry("bogus", func() {}); err == nil {
+		t.Error("expected an error for an invalid spec")
+	}
+}
+
+// Test that the entries added to a running Cron come back with their Next, even
+// when the adds are buffered and with dedup.
+func TestAddJobEntryRunning(t *testing.T) {
+	for _, opt := range []Option{WithAddBuffer(100), WithDedup()} {
+		clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+		cron := New(WithClock(clock), opt)
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+		for i := 0; i < 50; i++ {
+			entry, err := cron.AddFuncEntry(fmt.Sprintf("0 %d * * * ?", i+1), func() {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := getTime(fmt.Sprintf("Mon Jul 9 14:%02d 2012", i+1)).Local(); !entry.Next.Equal(expected) {
+				t.Errorf("entry %d: expected next %v, got %v", entry.ID, expected, entry.Next)
+			}
+		}
 		cancel()
-		ch <- true
-	}()
-	return ch
+	}
+}
+
+func TestAddFuncCancel(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan string, 10)
+	cron := New(WithClock(clock))
+	before, err := cron.AddFuncCancel("* * * * * ?", func() { ran <- "before" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	before()
+	if n := cron.Len(); n != 0 {
+		t.Fatalf("expected cancelling before Start to remove the entry, %d left", n)
+	}
+	cancelJob, _ := cron.AddFuncCancel("* * * * * ?", func() { ran <- "after" })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case name := <-ran:
+		if name != "after" {
+			t.Errorf("expected only the job not cancelled to run, %s ran", name)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the job to run")
+	}
+
+	cancelJob()
+	cancelJob()
+	clock.Advance(time.Second)
+	select {
+	case name := <-ran:
+		t.Errorf("expected no job to run after cancelling, %s ran", name)
+	case <-time.After(10 * time.Millisecond):
+	}
+	if n := cron.Len(); n != 0 {
+		t.Errorf("expected no entries left, got %d", n)
+	}
+
+	if _, err := cron.AddFuncCancel("bogus", func() {}); err == nil {
+		t.Error("expected an error for an invalid spec")
+	}
+}
+
+func TestRemoveGroup(t *testing.T) {
+	for _, running := range []bool{false, true} {
+		clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+		ran := make(chan string, 10)
+		cron := New(WithClock(clock))
+		ctx, cancel := context.WithCancel(context.Background())
+		if running {
+			cron.Start(ctx)
+		}
+		for i := 0; i < 3; i++ {
+			cron.AddFunc("* * * * * ?", func() { ran <- "tenant" }, WithGroup("tenant"))
+		}
+		other, _ := cron.AddFunc("* * * * * ?", func() { ran <- "other" }, WithGroup("other"))
+		cron.AddFunc("* * * * * ?", func() { ran <- "ungrouped" })
+
+		if n := cron.RemoveGroup(""); n != 0 {
+			t.Errorf("running %v: expected the empty group to remove nothing, removed %d", running, n)
+		}
+		if n := cron.RemoveGroup("tenant"); n != 3 {
+			t.Errorf("running %v: (expected) 3 != %d (actual) removed", running, n)
+		}
+		if n := cron.RemoveGroup("tenant"); n != 0 {
+			t.Errorf("running %v: expected nothing left to remove, removed %d", running, n)
+		}
+		if entry, ok := cron.EntryByID(other); !ok || entry.GroupID != "other" {
+			t.Errorf("running %v: expected the other group to be left, got %+v", running, entry)
+		}
+		if n := cron.Len(); n != 2 {
+			t.Errorf("running %v: (expected) 2 != %d (actual) entries", running, n)
+		}
+
+		if !running {
+			cron.Start(ctx)
+		}
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		for i := 0; i < 2; i++ {
+			select {
+			case name := <-ran:
+				if name == "tenant" {
+					t.Errorf("running %v: expected no job of the removed group to run", running)
+				}
+			case <-time.After(ONE_SECOND):
+				t.Fatalf("running %v: expected the other jobs to run", running)
+			}
+		}
+		cancel()
+	}
+}
+
+func TestNamedJobs(t *testing.T) {
+	for _, running := range []bool{false, true} {
+		cron := New()
+		ctx, cancel := context.WithCancel(context.Background())
+		if running {
+			cron.Start(ctx)
+		}
+
+		if err := cron.AddFuncNamed("nightly-backup", "0 0 3 * * ?", func() {}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cron.AddFuncNamed("nightly-backup", "0 0 4 * * ?", func() {}); err == nil {
+			t.Errorf("running %v: expected an error for a duplicate name", running)
+		}
+		if err := cron.AddFuncNamed("", "0 0 4 * * ?", func() {}); err == nil {
+			t.Errorf("running %v: expected an error for an empty name", running)
+		}
+
+		entry, ok := cron.EntryByName("nightly-backup")
+		if !ok || entry.Name != "nightly-backup" || running == entry.Next.IsZero() {
+			t.Errorf("running %v: unexpected entry %+v", running, entry)
+		}
+		if !cron.PauseByName("nightly-backup") {
+			t.Errorf("running %v: expected to pause the entry", running)
+		}
+		if entry, _ := cron.EntryByName("nightly-backup"); entry.Status != StatusPaused {
+			t.Errorf("running %v: expected the entry to be paused", running)
+		}
+		if !cron.ResumeByName("nightly-backup") || cron.PauseByName("missing") {
+			t.Errorf("running %v: unexpected result of resuming by name", running)
+		}
+		if !cron.RemoveByName("nightly-backup") || cron.Len() != 0 {
+			t.Errorf("running %v: expected to remove the entry", running)
+		}
+		if _, ok := cron.EntryByName("nightly-backup"); ok || cron.RemoveByName("nightly-backup") {
+			t.Errorf("running %v: expected the entry to be gone", running)
+		}
+		cancel()
+	}
+}
+
+func TestLabels(t *testing.T) {
+	cron := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	labels := map[string]string{"team": "ops"}
+	opsA, _ := cron.AddFunc("0 0 0 1 1 ?", func() {}, WithLabels(labels))
+	opsB, _ := cron.AddFunc("0 0 0 1 1 ?", func() {}, WithLabels(labels))
+	dev, _ := cron.AddFunc("0 0 0 1 1 ?", func() {}, WithLabels(map[string]string{"team": "dev"}))
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	labels["team"] = "changed"
+
+	if n := cron.PauseByLabel("team", "ops"); n != 2 {
+		t.Errorf("expected to pause 2 entries, paused %d", n)
+	}
+	for _, id := range []int64{opsA, opsB, dev} {
+		entry, _ := cron.EntryByID(id)
+		if paused := entry.Status == StatusPaused; paused != (id != dev) {
+			t.Errorf("entry %d: unexpected status %v", id, entry.Status)
+		}
+	}
+
+	entries := cron.EntriesByLabel("team", "ops")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	entries[0].Labels["team"] = "changed"
+	if n := cron.ResumeByLabel("team", "ops"); n != 2 {
+		t.Errorf("expected to resume 2 entries, resumed %d", n)
+	}
+	if n := len(cron.EntriesByLabel("team", "changed")); n != 0 {
+		t.Errorf("expected labels to be copied, found %d changed entries", n)
+	}
+}
+
+// logLines is an io.Writer for a log.Logger that sends the lines written on.
+type logLines chan string
+
+func (l logLines) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+func TestTick(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock), WithTestMode())
+	var minutely, hourly int
+	everyMinute, _ := cron.AddFunc("0 * * * * ?", func() { minutely++ })
+	everyHour, _ := cron.AddFunc("0 0 * * * ?", func() { hourly++ })
+	if ran, err := cron.Tick(getTime("Mon Jul 9 15:00 2012").Local()); ran != nil || !errors.Is(err, ErrNotRunning) {
+		t.Errorf("expected nothing to run before Start and ErrNotRunning, ran %v, got %v", ran, err)
+	}
+	if _, err := New().Tick(getTime("Mon Jul 9 15:00 2012").Local()); err == nil {
+		t.Error("expected an error without WithTestMode")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	ran, _ := cron.Tick(getTime("Mon Jul 9 14:03 2012").Local())
+	if expected := []int64{everyMinute, everyMinute, everyMinute}; !reflect.DeepEqual(ran, expected) {
+		t.Errorf("expected %v to run, ran %v", expected, ran)
+	}
+	if minutely != 3 || hourly != 0 {
+		t.Errorf("expected 3 and 0 runs, got %d and %d", minutely, hourly)
+	}
+
+	runs := map[int64]int{}
+	ran, _ = cron.Tick(getTime("Mon Jul 9 15:00:30 2012").Local())
+	for _, id := range ran {
+		runs[id]++
+	}
+	if runs[everyMinute] != 57 || runs[everyHour] != 1 {
+		t.Errorf("expected 57 minutely runs and an hourly one, ran %v", runs)
+	}
+	if minutely != 60 || hourly != 1 {
+		t.Errorf("expected 60 and 1 runs, got %d and %d", minutely, hourly)
+	}
+	if entry, _ := cron.EntryByID(everyHour); entry.RunCount != 1 || !entry.Next.Equal(getTime("Mon Jul 9 16:00 2012")) {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+}
+
+func TestAddFuncMulti(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 08:00 2012").Local())
+	cron := New(WithClock(clock), WithTestMode())
+	var runs int
+	id, err := cron.AddFuncMulti([]string{"0 0 9 * * MON-FRI", "0 0 18 * * SUN"}, func() { runs++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	ran, _ := cron.Tick(getTime("Mon Jul 16 00:00 2012").Local())
+	if len(ran) != 6 || runs != 6 {
+		t.Errorf("expected 5 weekday runs and a Sunday one, ran %v", ran)
+	}
+	entry, _ := cron.EntryByID(id)
+	if expected := getTime("Sun Jul 15 18:00 2012"); !entry.Prev.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual) Prev", expected, entry.Prev)
+	}
+	if expected := getTime("Mon Jul 16 09:00 2012"); !entry.Next.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual) Next", expected, entry.Next)
+	}
+	if expected := "0 0 9 * * MON-FRI | 0 0 18 * * SUN"; entry.Spec != expected {
+		t.Errorf("(expected) %q != %q (actual) Spec", expected, entry.Spec)
+	}
+
+	for _, specs := range [][]string{nil, {"0 0 9 * * *", "bogus"}} {
+		if _, err := cron.AddFuncMulti(specs, func() {}); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("%q: expected ErrInvalidSpec, got %v", specs, err)
+		}
+	}
+}
+
+func TestWithDayAnd(t *testing.T) {
+	clock := NewFakeClock(getTime("Sat Apr 14 00:00 2012").Local())
+	cron := New(WithClock(clock), WithDayAnd())
+	id, err := cron.AddFunc("0 0 0 13 * FRI", func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	expected := getTime("Fri Jul 13 00:00 2012").Local()
+	if entry, _ := cron.EntryByID(id); !entry.Next.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, entry.Next)
+	}
+}
+
+func TestStartWarning(t *testing.T) {
+	lines := make(logLines, 1)
+	cron := New(WithStartWarning(log.New(lines, "", 0), 10*time.Millisecond))
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	if cron.Started() {
+		t.Error("expected the cron not to have been started")
+	}
+	select {
+	case line := <-lines:
+		if expected := "cron: 2 entries pending, scheduler not started\n"; line != expected {
+			t.Errorf("expected %q, got %q", expected, line)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected a warning")
+	}
+
+	cron = New(WithStartWarning(log.New(lines, "", 0), 10*time.Millisecond))
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	cron.Start(ctx)
+	cancel()
+	if !cron.Started() {
+		t.Error("expected the cron to have been started")
+	}
+	select {
+	case line := <-lines:
+		t.Errorf("expected no warning, got %q", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestForEach(t *testing.T) {
+	for _, running := range []bool{false, true} {
+		cron := New()
+		ctx, cancel := context.WithCancel(context.Background())
+		if running {
+			cron.Start(ctx)
+		}
+		for i := 0; i < 5; i++ {
+			cron.AddFunc("0 0 0 1 1 ?", func() {})
+		}
+
+		var seen int
+		cron.ForEach(func(e Entry) bool {
+			seen++
+			return true
+		})
+		if seen != 5 {
+			t.Errorf("running %v: expected 5 entries, saw %d", running, seen)
+		}
+
+		seen = 0
+		cron.ForEach(func(e Entry) bool {
+			seen++
+			return seen < 2
+		})
+		if seen != 2 {
+			t.Errorf("running %v: expected to stop after 2 entries, saw %d", running, seen)
+		}
+		cancel()
+	}
+}
+
+func TestAddFuncWithID(t *testing.T) {
+	for _, running := range []bool{false, true} {
+		cron := New()
+		ctx, cancel := context.WithCancel(context.Background())
+		if running {
+			cron.Start(ctx)
+		}
+
+		if err := cron.AddFuncWithID(42, "0 0 3 * * ?", func() {}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cron.AddFuncWithID(42, "0 0 4 * * ?", func() {}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("running %v: expected ErrDuplicate, got %v", running, err)
+		}
+		if err := cron.AddFuncWithID(0, "0 0 4 * * ?", func() {}); err == nil {
+			t.Errorf("running %v: expected an error for id 0", running)
+		}
+		if entry, ok := cron.EntryByID(42); !ok || entry.Spec != "0 0 3 * * ?" {
+			t.Errorf("running %v: unexpected entry %+v", running, entry)
+		}
+		if id, _ := cron.AddFunc("0 0 5 * * ?", func() {}); id <= 42 {
+			t.Errorf("running %v: expected an id past 42, got %d", running, id)
+		}
+		cancel()
+	}
+}
+
+func TestNextEffective(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("0 0 * * * ?", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	expected := getTime("Mon Jul 9 15:00 2012").Local()
+	if next, ok := cron.NextEffective(id); !ok || !next.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, next)
+	}
+	cron.PauseFunc(id)
+	if next, ok := cron.NextEffective(id); !ok || !next.IsZero() {
+		t.Errorf("expected no effective next run while paused, got %v", next)
+	}
+	if next, _ := cron.GetNextRun(id); !next.Equal(expected) {
+		t.Errorf("expected GetNextRun to report %v while paused, got %v", expected, next)
+	}
+	cron.ResumeFunc(id)
+	cron.PauseAll()
+	if next, _ := cron.NextEffective(id); !next.IsZero() {
+		t.Errorf("expected no effective next run while the cron is paused, got %v", next)
+	}
+	cron.ResumeAll()
+	if next, _ := cron.NextEffective(id); !next.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, next)
+	}
+	if _, ok := cron.NextEffective(id + 1); ok {
+		t.Error("expected no entry for an unknown id")
+	}
+}
+
+// Test that a disabled entry restored from a snapshot stays disabled.
+func TestDisableRestore(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan struct{}, 10)
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("* * * * * ?", func() { ran <- struct{}{} })
+	if err := cron.Disable(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := cron.Enable(id + 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	restored := New(WithClock(clock))
+	restored.ReplaceAll(cron.Entries())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restored.Start(ctx)
+
+	entry, _ := restored.EntryByID(id)
+	if entry.Status != StatusPaused || entry.Next.IsZero() {
+		t.Errorf("expected a disabled entry with its next run time, got %+v", entry)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case <-ran:
+		t.Error("expected the disabled job not to run")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := restored.Enable(id); err != nil {
+		t.Fatal(err)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case <-ran:
+	case <-time.After(ONE_SECOND):
+		t.Error("expected the enabled job to run")
+	}
+}
+
+func TestMeta(t *testing.T) {
+	type owner struct{ name string }
+	meta := &owner{"ops"}
+	cron := New()
+	id, _ := cron.AddFunc("0 0 0 1 1 ?", func() {}, WithMeta(meta))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	entry, _ := cron.EntryByID(id)
+	if entry.Meta != meta {
+		t.Errorf("expected meta %v, got %v", meta, entry.Meta)
+	}
+
+	cron.ReplaceAll(cron.Entries())
+	if entry, _ := cron.EntryByID(id); entry.Meta != meta {
+		t.Errorf("expected meta to be kept by ReplaceAll, got %v", entry.Meta)
+	}
+}
+
+func TestRunning(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	started, release := make(chan struct{}), make(chan struct{})
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("* * * * * ?", func() {
+		started <- struct{}{}
+		<-release
+	})
+	cron.AddFunc("0 0 0 1 1 ?", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-started
+	if running := cron.Running(); len(running) != 1 || running[0] != id {
+		t.Errorf("expected [%d] to be running, got %v", id, running)
+	}
+
+	close(release)
+	waitForEntry(t, cron, id, func(e Entry) bool { return e.finishedCount == 1 })
+	if running := cron.Running(); len(running) != 0 {
+		t.Errorf("expected nothing to be running, got %v", running)
+	}
+}
+
+func TestBatchAdd(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	job := FuncJob(func() {})
+	ids, err := cron.BatchAdd([]string{"0 30 * * * ?", "0 0 15 * * ?"}, []Job{job, job})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("expected 2 distinct ids, got %v", ids)
+	}
+	for i, expected := range []string{"Mon Jul 9 14:30 2012", "Mon Jul 9 15:00 2012"} {
+		if entry, _ := cron.EntryByID(ids[i]); !entry.Next.Equal(getTime(expected).Local()) {
+			t.Errorf("entry %d: expected next %v, got %v", i, expected, entry.Next)
+		}
+	}
+
+	_, err = cron.BatchAdd([]string{"0 30 * * * ?", "bogus"}, []Job{job, job})
+	if err == nil || !strings.HasPrefix(err.Error(), "spec 1:") {
+		t.Errorf("expected an error naming spec 1, got %v", err)
+	}
+	if _, err := cron.BatchAdd([]string{"0 30 * * * ?"}, nil); err == nil {
+		t.Error("expected an error for mismatched lengths")
+	}
+	if n := cron.Len(); n != 2 {
+		t.Errorf("expected failed batches to add nothing, got %d entries", n)
+	}
+}
+
+type countJob struct{ n *int }
+
+func (j countJob) Run() { *j.n++ }
+
+func TestDedup(t *testing.T) {
+	for _, running := range []bool{false, true} {
+		cron := New(WithDedup())
+		ctx, cancel := context.WithCancel(context.Background())
+		if running {
+			cron.Start(ctx)
+		}
+
+		id, err := cron.AddFunc("0 0 3 * * ?", func() {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dup, err := cron.AddFunc("0  0 3 * * ?", func() {}); err != ErrDuplicate || dup != id {
+			t.Errorf("running %v: expected %d and ErrDuplicate, got %d and %v", running, id, dup, err)
+		}
+
+		var a, b int
+		if _, err := cron.AddJob("0 0 4 * * ?", countJob{&a}); err != nil {
+			t.Error(err)
+		}
+		if _, err := cron.AddJob("0 0 4 * * ?", countJob{&b}); err != nil {
+			t.Errorf("running %v: expected different jobs to be added, got %v", running, err)
+		}
+		if _, err := cron.AddJob("0 0 4 * * ?", countJob{&a}); err != ErrDuplicate {
+			t.Errorf("running %v: expected ErrDuplicate for the same job, got %v", running, err)
+		}
+
+		if n := cron.Len(); n != 3 {
+			t.Errorf("running %v: expected 3 entries, got %d", running, n)
+		}
+		if !cron.HasSpec("0 0 3 * * ?") || cron.HasSpec("0 0 5 * * ?") {
+			t.Errorf("running %v: unexpected HasSpec results", running)
+		}
+		cancel()
+	}
+}
+
+func TestGetNextAndPrevRun(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("* * * * * ?", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	waitForEntry(t, cron, id, func(e Entry) bool { return !e.Prev.IsZero() })
+
+	if next, ok := cron.GetNextRun(id); !ok || !next.Equal(getTime("Mon Jul 9 14:00:02 2012").Local()) {
+		t.Errorf("unexpected next run %v, %v", next, ok)
+	}
+	if prev, ok := cron.GetPrevRun(id); !ok || !prev.Equal(getTime("Mon Jul 9 14:00:01 2012").Local()) {
+		t.Errorf("unexpected previous run %v, %v", prev, ok)
+	}
+	if _, ok := cron.GetNextRun(id + 1); ok {
+		t.Error("expected an unknown id not to be found")
+	}
+	if _, ok := cron.GetPrevRun(id + 1); ok {
+		t.Error("expected an unknown id not to be found")
+	}
+}
+
+func TestTimeUntilNext(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("0 30 14 * * ?", func() {})
+	never, _ := cron.AddFunc("0 0 0 30 2 ?", func() {})
+	if _, ok := cron.TimeUntilNext(id); ok {
+		t.Error("expected no next run before starting")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	if d, ok := cron.TimeUntilNext(id); !ok || d != 30*time.Minute {
+		t.Errorf("expected 30m, got %v, %v", d, ok)
+	}
+	if _, ok := cron.TimeUntilNext(never); ok {
+		t.Error("expected no next run for an unsatisfiable schedule")
+	}
+	if _, ok := cron.TimeUntilNext(never + 1); ok {
+		t.Error("expected an unknown id not to be found")
+	}
+}
+
+func TestNextWake(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	cron.AddFunc("0 0 15 * * ?", func() {})
+	cron.AddFunc("0 0 0 30 2 ?", func() {})
+	if _, ok := cron.NextWake(); ok {
+		t.Error("expected no wake time before starting")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	cron.AddFunc("0 30 14 * * ?", func() {})
+	if next, ok := cron.NextWake(); !ok || !next.Equal(getTime("Mon Jul 9 14:30 2012").Local()) {
+		t.Errorf("unexpected wake time %v, %v", next, ok)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	desired, live := New(), New()
+	for _, c := range []struct {
+		cron *Cron
+		id   int64
+		spec string
+	}{
+		{desired, 1, "@daily"},
+		{live, 1, "0 0 0 * * *"}, // the same schedule
+		{desired, 2, "@hourly"},
+		{live, 2, "@every 1h"},  // changed
+		{desired, 3, "@weekly"}, // added
+		{live, 4, "@monthly"},   // removed
+	} {
+		if err := c.cron.AddFuncWithID(c.id, c.spec, func() {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(entries []Entry) []int64 {
+		var ids []int64
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	toAdd, toRemove, toUpdate := Diff(desired, live)
+	if actual := ids(toAdd); !reflect.DeepEqual(actual, []int64{3}) {
+		t.Errorf("(expected) [3] != %v (actual) to add", actual)
+	}
+	if actual := ids(toRemove); !reflect.DeepEqual(actual, []int64{4}) {
+		t.Errorf("(expected) [4] != %v (actual) to remove", actual)
+	}
+	if actual := ids(toUpdate); !reflect.DeepEqual(actual, []int64{2}) {
+		t.Errorf("(expected) [2] != %v (actual) to update", actual)
+	} else if toUpdate[0].Spec != "@hourly" {
+		t.Errorf("expected the desired entry to update to, got %q", toUpdate[0].Spec)
+	}
+
+	if toAdd, toRemove, toUpdate := Diff(live, live); toAdd != nil || toRemove != nil || toUpdate != nil {
+		t.Errorf("expected no changes, got %v, %v, %v", toAdd, toRemove, toUpdate)
+	}
+}
+
+func TestScheduleSummary(t *testing.T) {
+	cron := New()
+	for _, spec := range []string{"@daily", "@daily", "  @daily", "@every 1h", "0 30 9 * * MON-FRI"} {
+		if _, err := cron.AddFunc(spec, func() {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cron.AddSchedule(Every(time.Hour), FuncJob(func() {}))
+	weekdays, _ := Parse("0 30 9 * * MON-FRI")
+	cron.AddSchedule(weekdays, FuncJob(func() {}))
+
+	expected := map[string]int{
+		"@daily":             3,
+		"@every 1h0m0s":      2,
+		"0 30 9 * * mon-fri": 1,
+		"*cron.SpecSchedule": 1,
+	}
+	if summary := cron.ScheduleSummary(); !reflect.DeepEqual(summary, expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, summary)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	started, release := make(chan struct{}), make(chan struct{})
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("* * * * * ?", func() {
+		started <- struct{}{}
+		<-release
+	})
+	cron.AddFunc("0 0 15 * * ?", func() {})
+	cron.AddFunc("0 0 0 30 2 ?", func() {})
+	expected := HealthStatus{Entries: 3, Unsatisfiable: 1}
+	if status := cron.Health(); status != expected {
+		t.Errorf("before starting: (expected) %+v != %+v (actual)", expected, status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-started
+	waitForEntry(t, cron, id, func(e Entry) bool { return e.Next.Equal(clock.Now().Add(time.Second)) })
+	expected = HealthStatus{
+		Running:       true,
+		Entries:       3,
+		NextWake:      clock.Now().Add(time.Second),
+		Unsatisfiable: 1,
+		RunningJobs:   1,
+	}
+	status := cron.Health()
+	if status != expected {
+		t.Errorf("running: (expected) %+v != %+v (actual)", expected, status)
+	}
+	if next, _ := cron.NextWake(); !status.NextWake.Equal(next) || status.Entries != cron.Len() {
+		t.Errorf("expected the status to agree with NextWake and Len, got %+v", status)
+	}
+	close(release)
+}
+
+func TestDueWithin(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	soon, _ := cron.AddFunc("0 5 14 * * ?", func() {})
+	cron.AddFunc("0 0 15 * * ?", func() {})
+	sooner, _ := cron.AddFunc("0 2 14 * * ?", func() {})
+	edge, _ := cron.AddFunc("0 10 14 * * ?", func() {})
+	cron.AddFunc("0 0 0 30 2 ?", func() {})
+
+	var ids []int64
+	for _, e := range cron.DueWithin(10 * time.Minute) {
+		ids = append(ids, e.ID)
+	}
+	if expected := []int64{sooner, soon, edge}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, ids)
+	}
+	if due := cron.DueWithin(time.Minute); len(due) != 0 {
+		t.Errorf("expected nothing due within a minute, got %v", due)
+	}
+}
+
+// Test that a job added to run now runs right away, through the wrappers,
+// and then on its schedule.
+func TestAddFuncNow(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan string, 10)
+	wrapped := func(j Job) Job {
+		return FuncJob(func() {
+			ran <- "wrapper"
+			j.Run()
+		})
+	}
+	cron := New(WithClock(clock), WithChain(wrapped))
+	cron.AddFuncNow("0 30 14 * * ?", func() { ran <- "before start" })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	id, _ := cron.AddFuncNow("0 30 14 * * ?", func() { ran <- "running" })
+
+	seen := map[string]int{}
+	for len(seen) < 3 {
+		select {
+		case s := <-ran:
+			seen[s]++
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("expected both jobs to run right away, got %v", seen)
+		}
+	}
+	if seen["wrapper"] != 2 {
+		t.Errorf("expected the runs to go through the wrapper, got %v", seen)
+	}
+
+	entry := waitForEntry(t, cron, id, func(e Entry) bool { return e.finishedCount == 1 })
+	if !entry.Next.Equal(getTime("Mon Jul 9 14:30 2012").Local()) || entry.RunCount != 1 {
+		t.Errorf("unexpected entry after the immediate run: next %v, %d runs", entry.Next, entry.RunCount)
+	}
+}
+
+// Test that a failed add doesn't return an id that could be mistaken for a
+// real one.
+func TestAddJobErrorID(t *testing.T) {
+	cron := New()
+	cron.AddFunc("@hourly", func() {})
+	for _, spec := range []string{"garbage", "", "@every -1s"} {
+		id, err := cron.AddJob(spec, FuncJob(func() {}))
+		if err == nil || id > 0 {
+			t.Errorf("%q: expected a non-positive id and an error, got %d, %v", spec, id, err)
+		}
+		cron.PauseFunc(id)
+		if status := cron.Status(int(id)); status != StatusUnknown {
+			t.Errorf("%q: expected the id to be unknown, got status %v", spec, status)
+		}
+	}
+	for _, id := range []int64{0, -1} {
+		cron.PauseFunc(id)
+		if status := cron.Status(int(id)); status != StatusUnknown {
+			t.Errorf("id %d: expected it to be unknown, got status %v", id, status)
+		}
+	}
+	if entries := cron.Entries(); entries[0].Status != StatusRunning {
+		t.Errorf("expected the real entry to be untouched, got status %v", entries[0].Status)
+	}
+}
+
+// Test that errors can be told apart with errors.Is.
+func TestErrorSentinels(t *testing.T) {
+	cron := New()
+	if _, err := cron.AddFunc("0 75 * * * *", func() {}); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("AddFunc: expected ErrInvalidSpec, got %v", err)
+	}
+	if _, err := cron.BatchAdd([]string{"@hourly", "@fortnightly"}, []Job{FuncJob(func() {}), FuncJob(func() {})}); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("BatchAdd: expected ErrInvalidSpec, got %v", err)
+	}
+	if _, err := cron.AddSunFunc("sunsett", 59.33, 18.07, func() {}); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("AddSunFunc: expected ErrInvalidSpec, got %v", err)
+	}
+	cron.AddFuncNamed("job", "@hourly", func() {})
+	if err := cron.AddFuncNamed("job", "@daily", func() {}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("AddFuncNamed: expected ErrDuplicate, got %v", err)
+	}
+	if err := cron.ReplaceJob(42, FuncJob(func() {})); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReplaceJob: expected ErrNotFound, got %v", err)
+	}
+
+	for _, running := range []bool{false, true} {
+		if running {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cron.Start(ctx)
+		}
+		id, _ := cron.AddFunc("@hourly", func() {})
+		if err := cron.RemoveJobContext(context.Background(), id); err != nil {
+			t.Errorf("running %v: %v", running, err)
+		}
+		if err := cron.RemoveJobContext(context.Background(), id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("running %v: expected ErrNotFound, got %v", running, err)
+		}
+	}
+}
+
+func TestIsSatisfiable(t *testing.T) {
+	cron := New()
+	never, _ := cron.AddFunc("0 0 0 30 Feb ?", func() {})
+	hourly, _ := cron.AddFunc("@hourly", func() {})
+
+	for _, running := range []bool{false, true} {
+		if running {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cron.Start(ctx)
+		}
+		for id, expected := range map[int64]bool{never: false, hourly: true} {
+			if ok, err := cron.IsSatisfiable(id); ok != expected || err != nil {
+				t.Errorf("running %v, entry %d: expected %v, got %v (%v)", running, id, expected, ok, err)
+			}
+		}
+	}
+	if _, err := cron.IsSatisfiable(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// Test that a reset entry runs at the time computed from now, not the one
+// computed before.
+func TestReset(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan time.Time, 10)
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("@every 1h", func() { ran <- clock.Now() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	cron.PauseFunc(id)
+	clock.Set(getTime("Mon Jul 9 14:30 2012").Local())
+	cron.ResumeFunc(id)
+	if err := cron.Reset(id); err != nil {
+		t.Fatal(err)
+	}
+	expected := getTime("Mon Jul 9 15:30 2012").Local()
+	if next, _ := cron.GetNextRun(id); !next.Equal(expected) {
+		t.Fatalf("(expected) %v != %v (actual)", expected, next)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	select {
+	case at := <-ran:
+		if !at.Equal(expected) {
+			t.Errorf("expected the job to run at %v, ran at %v", expected, at)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the job to run")
+	}
+
+	if err := cron.Reset(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// Test that SetNext moves up the next run only, after which the schedule takes
+// over again.
+func TestSetNext(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan time.Time, 10)
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("0 0 * * * ?", func() { ran <- clock.Now() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	if err := cron.SetNext(id, getTime("Mon Jul 9 14:20 2012").Local()); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		advance  time.Duration
+		expected string
+	}{
+		{20 * time.Minute, "Mon Jul 9 14:20 2012"},
+		{40 * time.Minute, "Mon Jul 9 15:00 2012"},
+	} {
+		waitForEntry(t, cron, id, func(e Entry) bool { return e.Next.Equal(clock.Now().Add(step.advance)) })
+		clock.BlockUntil(1)
+		clock.Advance(step.advance)
+		select {
+		case at := <-ran:
+			if expected := getTime(step.expected).Local(); !at.Equal(expected) {
+				t.Errorf("expected the job to run at %v, ran at %v", expected, at)
+			}
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("expected the job to run at %v", step.expected)
+		}
+	}
+
+	if err := cron.SetNext(id, getTime("Mon Jul 9 14:30 2012").Local()); err == nil {
+		t.Error("expected an error for a time in the past")
+	}
+	if err := cron.SetNext(42, clock.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// Test that a suspended Cron keeps its entries, and runs them again once
+// started again.
+func TestSuspend(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan time.Time, 10)
+	cron := New(WithClock(clock))
+	cron.Suspend() // not started: no effect
+	hourly, _ := cron.AddFunc("0 0 * * * ?", func() { ran <- clock.Now() })
+	daily, _ := cron.AddFunc("@daily", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+
+	cron.Suspend()
+	if cron.IsRunning() {
+		t.Fatal("expected the Cron to be stopped")
+	}
+	clock.Advance(3*time.Hour + 30*time.Minute)
+	select {
+	case at := <-ran:
+		t.Fatalf("expected no run while suspended, ran at %v", at)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cron.Start(ctx)
+	var ids []int64
+	for _, e := range cron.Entries() {
+		ids = append(ids, e.ID)
+	}
+	if expected := []int64{hourly, daily}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("(expected) %v != %v (actual) entries", expected, ids)
+	}
+	expected := getTime("Mon Jul 9 18:00 2012").Local()
+	waitForEntry(t, cron, hourly, func(e Entry) bool { return e.Next.Equal(expected) })
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Minute)
+	select {
+	case at := <-ran:
+		if !at.Equal(expected) {
+			t.Errorf("expected the job to run at %v, ran at %v", expected, at)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the job to run after restarting")
+	}
+}
+
+// Test that Stop waits for the jobs running, and then calls OnStop once for
+// each entry, in id order, before returning.
+func TestStop(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	started := make(chan struct{})
+	var returned int32
+	cron := New(WithClock(clock))
+	var ids []int64
+	for _, spec := range []string{"@daily", "* * * * * ?", "@hourly"} {
+		id, _ := cron.AddFunc(spec, func() {})
+		ids = append(ids, id)
+	}
+	cron.ReplaceJob(ids[1], FuncJob(func() {
+		select {
+		case started <- struct{}{}:
+			time.Sleep(20 * time.Millisecond)
+			atomic.StoreInt32(&returned, 1)
+		default:
+		}
+	}))
+	var stopped []int64
+	cron.OnStop(func(e *Entry) {
+		if atomic.LoadInt32(&returned) == 0 {
+			t.Errorf("entry %d: OnStop called before the running job returned", e.ID)
+		}
+		stopped = append(stopped, e.ID)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-started
+
+	cron.Stop()
+	if cron.IsRunning() {
+		t.Error("expected the Cron to be stopped")
+	}
+	if !reflect.DeepEqual(stopped, ids) {
+		t.Errorf("(expected) %v != %v (actual)", ids, stopped)
+	}
+}
+
+func TestReplaceJob(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan string, 10)
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("* * * * * ?", func() { ran <- "old" })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	before, _ := cron.EntryByID(id)
+	if err := cron.ReplaceJob(id, FuncJob(func() { ran <- "new" })); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := cron.EntryByID(id); !after.Next.Equal(before.Next) || after.ID != id {
+		t.Errorf("expected the entry's timing to be kept, next %v became %v", before.Next, after.Next)
+	}
+	if err := cron.ReplaceJob(id+1, FuncJob(func() {})); err == nil {
+		t.Error("expected an error for an unknown id")
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case s := <-ran:
+		if s != "new" {
+			t.Errorf("expected the new job to run, got the %s one", s)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("job did not run")
+	}
+}
+
+// Test that entries may be added and inspected from several goroutines before
+// starting, and while starting.
+func TestConcurrentSetup(t *testing.T) {
+	cron := New()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				id, _ := cron.AddFunc("@hourly", func() {})
+				cron.PauseFunc(id)
+				cron.Status(int(id))
+				cron.Entries()
+			}
+		}()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cron.Start(ctx)
+	wg.Wait()
+
+	if n := len(cron.Entries()); n != 100 {
+		t.Errorf("expected 100 entries, got %d", n)
+	}
+}
+
+// Test that snapshots taken while entries are added and removed are each
+// consistent: without duplicates, and in order.
+func TestEntriesConsistent(t *testing.T) {
+	cron := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				id, _ := cron.AddFunc(fmt.Sprintf("0 %d %d * * ?", i%60, g), func() {})
+				if i%2 == 0 {
+					cron.RemoveJob(id)
+				}
+			}
+		}(g)
+	}
+
+	for i := 0; i < 200; i++ {
+		entries := cron.Entries()
+		seen := map[int64]bool{}
+		for j, e := range entries {
+			if seen[e.ID] {
+				t.Fatalf("snapshot %d: entry %d appears twice", i, e.ID)
+			}
+			seen[e.ID] = true
+			if j > 0 && e.Next.Before(entries[j-1].Next) {
+				t.Fatalf("snapshot %d: entry %d runs before the one listed before it", i, e.ID)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// Test that calls concurrent with shutdown don't wait for the stopped run loop,
+// and that the Cron can be started again.
+func TestShutdownUnblocksCallers(t *testing.T) {
+	cron := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	cron.Start(ctx)
+
+	returned := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			cron.Schedule(Every(time.Hour), FuncJob(func() {}), cron.nextID())
+			cron.Entries()
+		}
+		close(returned)
+	}()
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("calls concurrent with shutdown did not return promptly")
+	}
+
+	deadline := time.Now().Add(ONE_SECOND)
+	for cron.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the Cron to stop running")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := cron.Len(); n != 10 {
+		t.Errorf("expected 10 entries, got %d", n)
+	}
+
+	ran := make(chan struct{}, 1)
+	cron.AddFunc("* * * * * ?", func() { ran <- struct{}{} })
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	select {
+	case <-ran:
+	case <-time.After(ONE_SECOND):
+		t.Error("expected the restarted Cron to run jobs")
+	}
+}
+
+// Test that starting twice doesn't start a second run loop.
+func TestStartTwice(t *testing.T) {
+	cron := New()
+	if cron.IsRunning() {
+		t.Error("new cron should not be running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	if !cron.IsRunning() {
+		t.Error("cron should be running after Start")
+	}
+
+	goroutines := runtime.NumGoroutine()
+	cron.Start(ctx)
+	if n := runtime.NumGoroutine(); n != goroutines {
+		t.Errorf("second Start changed the number of goroutines from %d to %d", goroutines, n)
+	}
+}
+
+// Test that RunCount counts the runs of each entry, but not skipped ones.
+func TestRunCount(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan struct{}, 10)
+
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("* * * * * ?", func() { ran <- struct{}{} })
+	paused, _ := cron.AddFunc("* * * * * ?", func() {})
+	cron.PauseFunc(paused)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		<-ran
+	}
+
+	entry, ok := cron.EntryByID(id)
+	if !ok || entry.RunCount != 3 {
+		t.Errorf("(expected) 3 != %d (actual)", entry.RunCount)
+	}
+	entry, ok = cron.EntryByID(paused)
+	if !ok || entry.RunCount != 0 {
+		t.Errorf("paused entry: (expected) 0 != %d (actual)", entry.RunCount)
+	}
+	if _, ok := cron.EntryByID(100); ok {
+		t.Error("expected no entry for an unknown id")
+	}
+}
+
+// Test that the error of a failing ErrJob is recorded, and cleared again by a
+// successful run.
+func TestLastError(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	results := make(chan error, 2)
+	results <- errors.New("failed")
+	results <- nil
+
+	cron := New(WithClock(clock))
+	id, _ := cron.AddJob("* * * * * ?", ErrFuncJob(func() error { return <-results }))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	entry := waitForEntry(t, cron, id, func(e Entry) bool { return e.LastError != nil })
+	if entry.LastError.Error() != "failed" || !entry.LastErrorTime.Equal(clock.Now()) {
+		t.Errorf("unexpected error %v at %v", entry.LastError, entry.LastErrorTime)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	entry = waitForEntry(t, cron, id, func(e Entry) bool { return e.LastError == nil })
+	if !entry.LastErrorTime.IsZero() {
+		t.Errorf("expected the error time to be cleared, got %v", entry.LastErrorTime)
+	}
+}
+
+// Test that the duration of the last run is recorded.
+func TestLastDuration(t *testing.T) {
+	cron := New()
+	id, _ := cron.AddFunc("* * * * * ?", func() { time.Sleep(50 * time.Millisecond) })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	deadline := time.Now().Add(2 * ONE_SECOND)
+	for {
+		entry, _ := cron.EntryByID(id)
+		if entry.LastDuration != 0 {
+			if entry.LastDuration < 50*time.Millisecond || entry.AvgDuration != entry.LastDuration {
+				t.Errorf("unexpected durations: last %v, avg %v", entry.LastDuration, entry.AvgDuration)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no duration was recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobHooks(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	calls := make(chan string, 10)
+
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("* * * * * ?", func() { calls <- "run" })
+	cron.OnJobStart(func(e *Entry) {
+		if e.ID == id {
+			calls <- "start"
+		}
+	})
+	cron.OnJobEnd(func(e *Entry, d time.Duration) {
+		if e.ID == id {
+			calls <- "end"
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	for _, expected := range []string{"start", "run", "end"} {
+		select {
+		case actual := <-calls:
+			if actual != expected {
+				t.Fatalf("(expected) %s != %s (actual)", expected, actual)
+			}
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("timed out waiting for %s", expected)
+		}
+	}
+}
+
+func TestWithoutSeconds(t *testing.T) {
+	cron := New(WithoutSeconds())
+	if _, err := cron.AddFunc("30 * * * *", func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cron.AddFunc("0 30 * * * *", func() {}); err == nil {
+		t.Error("expected an error for a 6-field spec")
+	}
+
+	next := cron.Entries()[0].Schedule.Next(getTime("Mon Jul 9 14:00 2012"))
+	if expected := getTime("Mon Jul 9 14:30 2012"); next != expected {
+		t.Errorf("(expected) %v != %v (actual)", expected, next)
+	}
+}
+
+func TestAddFuncStandard(t *testing.T) {
+	cron := New()
+	if _, err := cron.AddFuncStandard("30 * * * *", func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cron.AddFuncStandard("0 30 * * * *", func() {}); err == nil {
+		t.Error("expected an error for a 6-field spec")
+	}
+
+	entry := cron.Entries()[0]
+	if next, expected := entry.Schedule.Next(getTime("Mon Jul 9 14:00 2012")), getTime("Mon Jul 9 14:30 2012"); next != expected {
+		t.Errorf("(expected) %v != %v (actual)", expected, next)
+	}
+	if entry.Spec != "30 * * * *" {
+		t.Errorf("unexpected spec %q", entry.Spec)
+	}
+}
+
+// waitForEntry polls the entry with the given id until cond holds.
+func waitForEntry(t *testing.T, cron *Cron, id int64, cond func(Entry) bool) Entry {
+	deadline := time.Now().Add(ONE_SECOND)
+	for {
+		entry, ok := cron.EntryByID(id)
+		if ok && cond(entry) {
+			return entry
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entry %d never reached the expected state: %+v", id, entry)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type testJob struct {
+	wg   *sync.WaitGroup
+	name string
+}
+
+func (t testJob) Run() {
+	t.wg.Done()
+}
+
+// Test that adds don't wait for a busy run loop with a buffer, and that the
+// buffered entries are added once it is free.
+func TestWithAddBuffer(t *testing.T) {
+	const n = 10
+	cron := New(WithAddBuffer(n))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	release, busy := make(chan struct{}), make(chan struct{})
+	go cron.do(func() {
+		close(busy)
+		<-release
+	})
+	<-busy
+
+	added := make(chan struct{})
+	go func() {
+		for i := 0; i < n; i++ {
+			cron.AddFunc("0 0 0 1 1 ?", func() {})
+		}
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(ONE_SECOND):
+		t.Fatal("adds blocked on the busy run loop")
+	}
+	close(release)
+
+	deadline := time.Now().Add(ONE_SECOND)
+	for cron.Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d entries, got %d", n, cron.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Test that calls made after buffered adds see the entries as added.
+func TestWithAddBufferOrder(t *testing.T) {
+	cron := New(WithAddBuffer(100))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	for i := 0; i < 200; i++ {
+		id, _ := cron.AddFunc("0 0 0 1 1 ?", func() {})
+		if err := cron.RemoveJobContext(ctx, id); err != nil {
+			t.Fatalf("removing entry %d right after adding it: %v", id, err)
+		}
+		id, _ = cron.AddFunc("0 0 0 1 1 ?", func() {})
+		if err := cron.Disable(id); err != nil {
+			t.Fatalf("disabling entry %d right after adding it: %v", id, err)
+		}
+		cron.RemoveJob(id)
+	}
+	if n := cron.Len(); n != 0 {
+		t.Errorf("expected no entries left, got %d", n)
+	}
+}
+
+// Test that runs missed while the scheduler was asleep are skipped, or run
+// once with WithCatchUp.
+func TestCatchUp(t *testing.T) {
+	for _, catchUp := range []bool{false, true} {
+		clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+		ran := make(chan struct{}, 10)
+		opts := []Option{WithClock(clock)}
+		if catchUp {
+			opts = append(opts, WithCatchUp())
+		}
+		cron := New(opts...)
+		id, _ := cron.AddFunc("0 * * * * ?", func() { ran <- struct{}{} })
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+
+		clock.BlockUntil(1)
+		clock.Advance(10*time.Minute + 30*time.Second)
+		entry := waitForEntry(t, cron, id, func(e Entry) bool { return e.Next.After(clock.Now()) })
+		if expected := getTime("Mon Jul 9 14:11 2012").Local(); !entry.Next.Equal(expected) {
+			t.Errorf("catch up %v: expected next %v, got %v", catchUp, expected, entry.Next)
+		}
+
+		runs := 0
+		if catchUp {
+			select {
+			case <-ran:
+				runs++
+			case <-time.After(ONE_SECOND):
+			}
+		}
+		select {
+		case <-ran:
+			runs++
+		case <-time.After(50 * time.Millisecond):
+		}
+		if expected := map[bool]int{false: 0, true: 1}[catchUp]; runs != expected {
+			t.Errorf("catch up %v: expected %d runs, got %d", catchUp, expected, runs)
+		}
+		cancel()
+	}
+}
+
+// Test that jobs are handed their entry in the context.
+func TestEntryFromContext(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	entries := make(chan *Entry, 1)
+	cron := New(WithClock(clock))
+	id, _ := cron.AddJob("* * * * * ?", ContextFuncJob(func(ctx context.Context) error {
+		entry, _ := EntryFromContext(ctx)
+		entries <- entry
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	select {
+	case entry := <-entries:
+		if entry == nil || entry.ID != id || !entry.Next.Equal(getTime("Mon Jul 9 14:00:01 2012").Local()) {
+			t.Errorf("unexpected entry in context: %+v", entry)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("job did not run")
+	}
+	if _, ok := EntryFromContext(context.Background()); ok {
+		t.Error("expected no entry outside of a run")
+	}
+}
+
+// Test that the scheduler wakes up every wake interval without running jobs
+// early.
+func TestWithWakeInterval(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan time.Time, 1)
+	cron := New(WithClock(clock), WithWakeInterval(time.Minute))
+	cron.AddFunc("0 0 15 * * ?", func() { ran <- clock.Now() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	for i := 0; i < 3; i++ {
+		// The loop only waits again if it woke up.
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+	}
+	select {
+	case at := <-ran:
+		t.Fatalf("job ran early, at %v", at)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.BlockUntil(1)
+	clock.Set(getTime("Mon Jul 9 15:00 2012").Local())
+	select {
+	case <-ran:
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the job to run once due")
+	}
+}
+
+// Test that after the clock is set back, jobs run by the time it reads rather
+// than waiting for the times computed before.
+func TestClockSetBack(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan time.Time, 10)
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("0 0 * * * ?", func() { ran <- clock.Now() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	clock.Set(getTime("Mon Jul 9 12:30 2012").Local())
+	cron.Len() // Have the scheduler take a look.
+	expected := getTime("Mon Jul 9 13:00 2012").Local()
+	if entry := waitForEntry(t, cron, id, func(e Entry) bool { return e.Next.Equal(expected) }); !entry.Next.Equal(expected) {
+		t.Fatalf("(expected) %v != %v (actual)", expected, entry.Next)
+	}
+
+	clock.BlockUntil(2)
+	clock.Advance(30 * time.Minute)
+	select {
+	case at := <-ran:
+		if !at.Equal(expected) {
+			t.Errorf("expected the job to run at %v, ran at %v", expected, at)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the job to run at the time the clock reads")
+	}
+}
+
+// Test that an idle scheduler takes another look after the idle horizon.
+func TestWithIdleHorizon(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock), WithIdleHorizon(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	done := make(chan struct{})
+	go func() {
+		clock.BlockUntil(1)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the scheduler to sleep again after the idle horizon")
+	}
+}
+
+// Test that an idle scheduler, and one waiting for an entry far off, take
+// another look after a day rather than sleeping for years.
+func TestMaxSleep(t *testing.T) {
+	start := getTime("Mon Jul 9 14:00 2012").Local()
+	wakeUp := func(clock *FakeClock) time.Time {
+		clock.BlockUntil(1)
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return clock.waiters[0].until
+	}
+	for _, spec := range []string{"", "0 0 0 1 1 ?"} {
+		clock := NewFakeClock(start)
+		cron := New(WithClock(clock))
+		if spec != "" {
+			if _, err := cron.AddFunc(spec, func() {}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+		for day := 1; day <= 3; day++ {
+			if at, expected := wakeUp(clock), start.Add(time.Duration(day)*maxSleep); !at.Equal(expected) {
+				t.Errorf("%q: (expected) %v != %v (actual) wake up", spec, expected, at)
+			}
+			clock.Advance(maxSleep)
+		}
+		cancel()
+	}
+}
+
+// Test that a job can ask for its next run to be at a time of its choosing,
+// after which its schedule is followed again, also when run on the scheduler's
+// goroutine.
+func TestReschedule(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		opts      []Option
+		entryOpts []EntryOption
+	}{
+		{"goroutine", nil, nil},
+		{"WithInlineExecution", []Option{WithInlineExecution()}, nil},
+		{"WithSyncFirstRun", nil, []EntryOption{WithSyncFirstRun()}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			clock := NewFakeClock(getTime("Mon Jul 9 13:59:59 2012").Local())
+			ran := make(chan struct{}, 10)
+			var runs int64
+			cron := New(append(c.opts, WithClock(clock))...)
+			id, _ := cron.AddJob("0 0 * * * ?", ContextFuncJob(func(ctx context.Context) error {
+				if atomic.AddInt64(&runs, 1) == 1 && !Reschedule(ctx, clock.Now().Add(10*time.Second)) {
+					t.Error("expected the run's context to allow rescheduling")
+				}
+				ran <- struct{}{}
+				return nil
+			}), c.entryOpts...)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cron.Start(ctx)
+
+			for _, tick := range []struct {
+				advance  time.Duration
+				expected string
+			}{
+				{time.Second, "Mon Jul 9 14:00:10 2012"},
+				{10 * time.Second, "Mon Jul 9 15:00 2012"},
+			} {
+				clock.BlockUntil(1)
+				clock.Advance(tick.advance)
+				select {
+				case <-ran:
+				case <-time.After(ONE_SECOND):
+					t.Fatalf("expected the job to run at %v", clock.Now())
+				}
+				expected := getTime(tick.expected).Local()
+				entry := waitForEntry(t, cron, id, func(e Entry) bool { return e.Next.Equal(expected) })
+				if !entry.Next.Equal(expected) {
+					t.Errorf("(expected) %v != %v (actual)", expected, entry.Next)
+				}
+			}
+		})
+	}
+
+	if Reschedule(context.Background(), time.Now()) {
+		t.Error("expected rescheduling outside of a run to fail")
+	}
+}
+
+// Test that jobs due with a synchronous first run see its effects.
+func TestSyncFirstRun(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	var ready int32
+	seen := make(chan bool, 10)
+	cron := New(WithClock(clock))
+	cron.AddFunc("* * * * * ?", func() { seen <- atomic.LoadInt32(&ready) == 1 })
+	cron.AddFunc("* * * * * ?", func() {
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&ready, 1)
+	}, WithSyncFirstRun())
+	cron.AddFunc("* * * * * ?", func() { seen <- atomic.LoadInt32(&ready) == 1 })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case ok := <-seen:
+			if !ok {
+				t.Error("expected the setup job to have run first")
+			}
+		case <-time.After(ONE_SECOND):
+			t.Fatal("expected the dependent jobs to run")
+		}
+	}
+}
+
+// Test that a job removed while it runs never runs again, and that its run is
+// cancelled with WithCancelOnRemove.
+func TestRemoveWhileRunning(t *testing.T) {
+	for _, cancelOnRemove := range []bool{false, true} {
+		clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+		started, cancelled := make(chan struct{}, 10), make(chan bool, 10)
+		release := make(chan struct{})
+		opts := []Option{WithClock(clock)}
+		if cancelOnRemove {
+			opts = append(opts, WithCancelOnRemove())
+		}
+		cron := New(opts...)
+		id, _ := cron.AddJob("* * * * * ?", ContextFuncJob(func(ctx context.Context) error {
+			started <- struct{}{}
+			select {
+			case <-ctx.Done():
+				cancelled <- true
+			case <-release:
+				cancelled <- false
+			}
+			return nil
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		<-started
+
+		cron.RemoveJob(id)
+		if !cancelOnRemove {
+			close(release)
+		}
+		select {
+		case c := <-cancelled:
+			if c != cancelOnRemove {
+				t.Errorf("cancel on remove %v: expected the run to be cancelled %v", cancelOnRemove, cancelOnRemove)
+			}
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("cancel on remove %v: expected the run to end", cancelOnRemove)
+		}
+
+		clock.Advance(time.Second)
+		clock.Advance(time.Second)
+		select {
+		case <-started:
+			t.Errorf("cancel on remove %v: expected the removed job not to run again", cancelOnRemove)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if n := len(cron.Entries()); n != 0 {
+			t.Errorf("cancel on remove %v: expected no entries, got %d", cancelOnRemove, n)
+		}
+		cancel()
+	}
+}
+
+// Test that ReplaceAll cancels the runs of the entries it drops with
+// WithCancelOnRemove, and leaves those of the ids it keeps alone.
+func TestReplaceAllCancelsDropped(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock), WithCancelOnRemove())
+	started, cancelled := make(chan int64, 10), make(chan int64, 10)
+	release := make(chan struct{})
+	defer close(release)
+	for _, id := range []int64{1, 2} {
+		id := id
+		cron.AddJobWithID(id, "* * * * * ?", ContextFuncJob(func(ctx context.Context) error {
+			started <- id
+			select {
+			case <-ctx.Done():
+				cancelled <- id
+			case <-release:
+			}
+			return nil
+		}))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-started
+	<-started
+
+	kept, _ := cron.EntryByID(1)
+	if err := cron.ReplaceAll([]*Entry{&kept}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-cancelled:
+		if id != 2 {
+			t.Errorf("expected the run of entry 2 to be cancelled, got %d", id)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected the run of the dropped entry to be cancelled")
+	}
+	select {
+	case id := <-cancelled:
+		t.Errorf("expected the run of the kept entry to go on, got %d cancelled", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Test that the latency of a run is how long after it was due it started.
+func TestLastLatency(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	finished := make(chan struct{}, 1)
+	cron := New(WithClock(clock))
+	cron.OnJobStart(func(*Entry) { clock.Advance(3 * time.Second) })
+	cron.OnJobEnd(func(*Entry, time.Duration) { finished <- struct{}{} })
+	id, _ := cron.AddFunc("1 0 14 * * ?", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-finished
+
+	entry := waitForEntry(t, cron, id, func(e Entry) bool { return e.LastLatency != 0 })
+	if entry.LastLatency != 3*time.Second {
+		t.Errorf("expected a latency of 3s, got %v", entry.LastLatency)
+	}
+}
+
+// Test that with inline execution the jobs due at once run one after the other.
+func TestWithInlineExecution(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	var running int32
+	overlapped := make(chan bool, 3)
+	cron := New(WithClock(clock), WithInlineExecution())
+	for i := 0; i < 3; i++ {
+		cron.AddFunc("1 0 14 * * ?", func() {
+			n := atomic.AddInt32(&running, 1)
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			overlapped <- n > 1
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case o := <-overlapped:
+			if o {
+				t.Error("expected the jobs not to overlap")
+			}
+		case <-time.After(ONE_SECOND):
+			t.Fatal("expected the jobs to run")
+		}
+	}
+}
+
+// Test that a dry run logs the runs that are due instead of running the jobs,
+// advancing the entries as if they ran.
+func TestWithDryRun(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	lines := make(logLines, 10)
+	cron := New(WithClock(clock), WithTestMode(), WithDryRun(log.New(lines, "", 0)))
+	ran := false
+	id, _ := cron.AddFunc("0 * * * * ?", func() { ran = true })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	cron.Tick(getTime("Mon Jul 9 14:02 2012").Local())
+
+	if ran {
+		t.Error("expected the job not to run")
+	}
+	for _, due := range []string{"Mon Jul 9 14:01 2012", "Mon Jul 9 14:02 2012"} {
+		expected := fmt.Sprintf("cron: dry run: entry %d due at %v\n", id, getTime(due).Local())
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Errorf("expected %q, got %q", expected, line)
+			}
+		default:
+			t.Fatalf("expected the run due at %v to be logged", due)
+		}
+	}
+	entry, _ := cron.EntryByID(id)
+	if !entry.Prev.Equal(getTime("Mon Jul 9 14:02 2012").Local()) || !entry.Next.Equal(getTime("Mon Jul 9 14:03 2012").Local()) {
+		t.Errorf("expected the entry to advance, got prev %v, next %v", entry.Prev, entry.Next)
+	}
+}
+
+// Test what each overlap policy does with a run that is due while the job is
+// still running.
+func TestWithOverlapPolicy(t *testing.T) {
+	for _, c := range []struct {
+		policy OverlapPolicy
+		// The runs started while the first one runs, and after it finishes.
+		during, after int
+	}{
+		{OverlapAllow, 1, 0},
+		{OverlapSkip, 0, 0},
+		{OverlapQueue, 0, 1},
+	} {
+		clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+		cron := New(WithClock(clock), WithOverlapPolicy(c.policy))
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+		id, _ := cron.AddFunc("* * * * * ?", func() {
+			started <- struct{}{}
+			<-release
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		select {
+		case <-started:
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("policy %d: expected the job to start", c.policy)
+		}
+
+		// The slow job is due again while it runs.
+		second := clock.Now()
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		waitForEntry(t, cron, id, func(e Entry) bool { return e.Next.After(second.Add(time.Second)) })
+		time.Sleep(10 * time.Millisecond)
+		if during := len(started); during != c.during {
+			t.Errorf("policy %d: (expected) %d != %d (actual) runs started while running", c.policy, c.during, during)
+		}
+
+		for len(started) > 0 {
+			<-started
+		}
+		close(release)
+		time.Sleep(10 * time.Millisecond)
+		if after := len(started); after != c.after {
+			t.Errorf("policy %d: (expected) %d != %d (actual) runs started after", c.policy, c.after, after)
+		}
+		cancel()
+	}
+}
+
+// Test that runs skipped as the job is still running are not counted as runs,
+// while Next keeps following the schedule.
+func TestOverlapSkipAccounting(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock), WithOverlapPolicy(OverlapSkip))
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	id, _ := cron.AddFunc("* * * * * ?", func() {
+		started <- struct{}{}
+		<-release
+	})
+	events := cron.Events()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-started
+	first := clock.Now()
+
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	expected := first.Add(4 * time.Second)
+	waitForEntry(t, cron, id, func(e Entry) bool { return e.Next.Equal(expected) })
+	entry, _ := cron.EntryByID(id)
+	if entry.RunCount != 1 || !entry.Prev.Equal(first) {
+		t.Errorf("expected 1 run at %v, got %d at %v", first, entry.RunCount, entry.Prev)
+	}
+	skipped := 0
+	for len(events) > 0 {
+		if event := <-events; event.Type == EventJobSkipped {
+			skipped++
+		}
+	}
+	if skipped != 3 {
+		t.Errorf("(expected) 3 != %d (actual) skipped runs", skipped)
+	}
+	close(release)
+}
+
+// Test that a run of WithRunNow skipped for overlapping doesn't move Prev.
+func TestOverlapSkipRunNow(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock), WithOverlapPolicy(OverlapSkip))
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	defer close(release)
+	id, _ := cron.AddFunc("@every 1h", func() {
+		started <- struct{}{}
+		<-release
+	}, WithRunNow())
+	events := cron.Events()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	<-started
+	first := clock.Now()
+
+	clock.Advance(time.Minute)
+	cron.do(func() {
+		cron.entryByID(id).runNow = true
+		cron.pendingNow = true
+	})
+	for event := range events {
+		if event.Type == EventJobSkipped {
+			break
+		}
+	}
+	if entry, _ := cron.EntryByID(id); entry.RunCount != 1 || !entry.Prev.Equal(first) {
+		t.Errorf("expected 1 run at %v, got %d at %v", first, entry.RunCount, entry.Prev)
+	}
+}
+
+// Test that of the entries due at the same time, those with a higher priority
+// start first.
+func TestWithPriority(t *testing.T) {
+	for _, inline := range []bool{false, true} {
+		clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+		opts := []Option{WithClock(clock)}
+		if inline {
+			opts = append(opts, WithInlineExecution())
+		}
+		cron := New(opts...)
+		events := cron.Events()
+		ran := make(chan int64, 10)
+		var expected []int64
+		for _, priority := range []int{0, 5, -1, 10, 5} {
+			var id int64
+			id, _ = cron.AddFunc("1 0 14 * * ?", func() { ran <- id }, WithPriority(priority))
+			expected = append(expected, id)
+		}
+		// By priority; the two of priority 5 may start in either order.
+		expected = []int64{expected[3], expected[1], expected[4], expected[0], expected[2]}
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+
+		var started []int64
+		for len(started) < len(expected) {
+			select {
+			case event := <-events:
+				if event.Type == EventJobStarted {
+					started = append(started, event.ID)
+				}
+			case <-time.After(ONE_SECOND):
+				t.Fatalf("inline %v: expected the jobs to start", inline)
+			}
+		}
+		if !sameOrderAmongEqual(started, expected) {
+			t.Errorf("inline %v: (expected) %v != %v (actual) start order", inline, expected, started)
+		}
+		if inline {
+			var order []int64
+			for range expected {
+				order = append(order, <-ran)
+			}
+			if !sameOrderAmongEqual(order, expected) {
+				t.Errorf("(expected) %v != %v (actual) run order", expected, order)
+			}
+		}
+		cancel()
+	}
+}
+
+// sameOrderAmongEqual reports whether ids is expected, but for the order of the
+// second and third, which have the same priority in TestWithPriority.
+func sameOrderAmongEqual(ids, expected []int64) bool {
+	swapped := append([]int64{}, expected...)
+	swapped[1], swapped[2] = swapped[2], swapped[1]
+	return reflect.DeepEqual(ids, expected) || reflect.DeepEqual(ids, swapped)
+}
+
+// Test that no more than the maximum number of jobs run at once.
+func TestWithMaxConcurrency(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	var running, most int64
+	release := make(chan struct{})
+	cron := New(WithClock(clock), WithMaxConcurrency(2))
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		id, _ := cron.AddFunc("1 0 14 * * ?", func() {
+			n := atomic.AddInt64(&running, 1)
+			for {
+				m := atomic.LoadInt64(&most)
+				if n <= m || atomic.CompareAndSwapInt64(&most, m, n) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt64(&running, -1)
+		})
+		ids = append(ids, id)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	// Give the queued jobs a chance to start, should the limit not hold.
+	deadline := time.Now().Add(ONE_SECOND)
+	for atomic.LoadInt64(&running) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for _, id := range ids {
+		waitForEntry(t, cron, id, func(e Entry) bool { return e.finishedCount == 1 })
+	}
+	if most := atomic.LoadInt64(&most); most != 2 {
+		t.Errorf("expected at most 2 jobs to run at once, got %d", most)
+	}
+}
+
+// Test that with a fair order, the job that waits longest for a slot changes
+// across coincident ticks.
+func TestWithFairOrder(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan int64, 10)
+	cron := New(WithClock(clock), WithMaxConcurrency(1), WithFairOrder())
+	for i := 0; i < 3; i++ {
+		var id int64
+		id, _ = cron.AddFunc("* * * * * ?", func() { ran <- id })
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	last := map[int64]bool{}
+	for tick := 0; tick < 3; tick++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		var id int64
+		for i := 0; i < 3; i++ {
+			select {
+			case id = <-ran:
+			case <-time.After(ONE_SECOND):
+				t.Fatalf("tick %d: expected all three jobs to run", tick)
+			}
+		}
+		last[id] = true
+	}
+	if len(last) != 3 {
+		t.Errorf("expected each job to be last once, got %v", last)
+	}
+}
+
+// Test that starting keeps the next run times the entries already have, and
+// computes the missing and stale ones.
+func TestStartKeepsNext(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	kept, _ := cron.AddFunc("0 0 15 * * ?", func() {})
+	stale, _ := cron.AddFunc("0 0 16 * * ?", func() {})
+	missing, _ := cron.AddFunc("0 0 17 * * ?", func() {})
+	cron.entryByID(kept).Next = getTime("Mon Jul 9 14:45 2012").Local()
+	cron.entryByID(stale).Next = getTime("Mon Jul 9 13:00 2012").Local()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	for id, expected := range map[int64]string{
+		kept:    "Mon Jul 9 14:45 2012",
+		stale:   "Mon Jul 9 16:00 2012",
+		missing: "Mon Jul 9 17:00 2012",
+	} {
+		entry := waitForEntry(t, cron, id, func(e Entry) bool { return !e.Next.IsZero() })
+		if !entry.Next.Equal(getTime(expected).Local()) {
+			t.Errorf("entry %d: expected next %v, got %v", id, expected, entry.Next)
+		}
+	}
+}
+
+// The first activation of a delay schedule counts from Start, even if the entry
+// already has one, while that of a spec schedule stays aligned to the clock.
+func TestStartFirstNext(t *testing.T) {
+	start := getTime("Mon Jul 9 14:10 2012").Local()
+	cron := New(WithClock(NewFakeClock(start)))
+	every, _ := cron.AddFunc("@every 1h", func() {})
+	kept, _ := cron.AddFunc("@every 1h", func() {})
+	precise, _ := cron.AddFunc("@every 250ms", func() {})
+	jittered := cron.AddSchedule(WithJitterSource(0, Every(time.Hour), rand.NewSource(1)), FuncJob(func() {}))
+	daily, _ := cron.AddFunc("@daily", func() {})
+	cron.entryByID(kept).Next = getTime("Mon Jul 9 14:45 2012").Local()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	for id, expected := range map[int64]time.Time{
+		every:    start.Add(time.Hour),
+		kept:     start.Add(time.Hour),
+		precise:  start.Add(250 * time.Millisecond),
+		jittered: start.Add(time.Hour),
+		daily:    getTime("Tue Jul 10 00:00 2012").Local(),
+	} {
+		entry := waitForEntry(t, cron, id, func(e Entry) bool { return !e.Next.IsZero() })
+		if !entry.Next.Equal(expected) {
+			t.Errorf("entry %d: expected next %v, got %v", id, expected, entry.Next)
+		}
+	}
+}
+
+// Simple test using Runnables.
+func TestJob(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	cron := New()
+	cron.AddJob("0 0 0 30 Feb ?", testJob{wg, "job0"})
+	cron.AddJob("0 0 0 1 1 ?", testJob{wg, "job1"})
+	cron.AddJob("* * * * * ?", testJob{wg, "job2"})
+	cron.AddJob("1 0 0 1 1 ?", testJob{wg, "job3"})
+	cron.Schedule(Every(5*time.Second+5*time.Nanosecond), testJob{wg, "job4"}, 1)
+	cron.Schedule(Every(5*time.Minute), testJob{wg, "job5"}, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	select {
+	case <-time.After(ONE_SECOND):
+		t.FailNow()
+	case <-wait(wg):
+	}
+
+	// Ensure the entries are in the right order.
+	expecteds := []string{"job2", "job4", "job5", "job1", "job3", "job0"}
+
+	var actuals []string
+	for _, entry := range cron.Entries() {
+		actuals = append(actuals, entry.Job.(testJob).name)
+	}
+
+	for i, expected := range expecteds {
+		if actuals[i] != expected {
+			t.Errorf("Jobs not in the right order.  (expected) %s != %s (actual)", expecteds, actuals)
+			t.FailNow()
+		}
+	}
+}
+
+func wait(wg *sync.WaitGroup) chan bool {
+	ch := make(chan bool)
+	go func() {
+		wg.Wait()
+		ch <- true
+	}()
+	return ch
+}
+
+func stop(cancel context.CancelFunc) chan bool {
+	ch := make(chan bool)
+	go func() {
+		cancel()
+		ch <- true
+	}()
+	return ch
+}
+
+// benchmarkEntries returns n entries with random next times, as the run loop
+// would have them.
+func benchmarkEntries(n int) []*Entry {
+	start := getTime("Mon Jul 9 14:00 2012")
+	r := rand.New(rand.NewSource(1))
+	entries := make([]*Entry, n)
+	for i := range entries {
+		entries[i] = &Entry{ID: int64(i), Next: start.Add(time.Duration(r.Int63n(int64(24 * time.Hour))))}
+	}
+	return entries
+}
+
+// Benchmark finding the next of 50k entries and rescheduling it, by sorting
+// all of them each time as the run loop used to.
+func BenchmarkNextEntrySort(b *testing.B) {
+	entries := benchmarkEntries(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sort.Sort(byTime(entries))
+		entries[0].Next = entries[0].Next.Add(24 * time.Hour)
+	}
+}
+
+// Benchmark finding the next of 50k entries and rescheduling it, by keeping
+// them as a heap as the run loop does.
+func BenchmarkNextEntryHeap(b *testing.B) {
+	entries := benchmarkEntries(50000)
+	h := (*byTime)(&entries)
+	heap.Init(h)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entries[0].Next = entries[0].Next.Add(24 * time.Hour)
+		heap.Fix(h, 0)
+	}
+}
+
+// Benchmark dispatching 10k trivial jobs, each in its own goroutine or inline
+// with WithInlineExecution.
+func BenchmarkDispatchGoroutine(b *testing.B) { benchmarkDispatch(b, false) }
+func BenchmarkDispatchInline(b *testing.B)    { benchmarkDispatch(b, true) }
+
+func benchmarkDispatch(b *testing.B, inline bool) {
+	var opts []Option
+	if inline {
+		opts = append(opts, WithInlineExecution())
+	}
+	cron := New(opts...)
+	entries := benchmarkEntries(10000)
+	for _, e := range entries {
+		e.Job = FuncJob(func() {})
+	}
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range entries {
+			cron.startJob(ctx, e, e.Next)
+		}
+		if !inline {
+			for range entries {
+				cron.finish(ctx, <-cron.finished)
+			}
+		}
+	}
+}
+
+// Benchmark adding bursts of 1000 entries to a running Cron, which takes them
+// from its add buffer in one go.
+func BenchmarkAddBurst(b *testing.B) {
+	cron := New(WithAddBuffer(1000))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1000; j++ {
+			cron.AddFunc("0 0 0 1 1 ?", func() {})
+		}
+		cron.RemoveAll()
+	}
 }
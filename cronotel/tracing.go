@@ -0,0 +1,51 @@
+// Package cronotel traces the runs of cron jobs with OpenTelemetry. It is a
+// separate package so that only users of OpenTelemetry depend on it.
+package cronotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonaz/cron"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the creator of the spans.
+const instrumentationName = "github.com/jonaz/cron/cronotel"
+
+// WithTracing returns a JobWrapper that runs each job in a new root span from
+// the given provider. The span carries the id and the due and previous run
+// times of the entry, and records the error of a failed run. Its context is
+// handed to ContextJobs, so that their spans nest under it.
+func WithTracing(tp trace.TracerProvider) cron.JobWrapper {
+	tracer := tp.Tracer(instrumentationName)
+	return func(j cron.Job) cron.Job {
+		return cron.ContextFuncJob(func(ctx context.Context) error {
+			opts := []trace.SpanStartOption{
+				trace.WithNewRoot(),
+				trace.WithSpanKind(trace.SpanKindInternal),
+			}
+			if entry, ok := cron.EntryFromContext(ctx); ok {
+				attrs := []attribute.KeyValue{
+					attribute.Int64("cron.entry.id", entry.ID),
+					attribute.String("cron.entry.next", entry.Next.Format(time.RFC3339Nano)),
+				}
+				if !entry.Prev.IsZero() {
+					attrs = append(attrs, attribute.String("cron.entry.prev", entry.Prev.Format(time.RFC3339Nano)))
+				}
+				opts = append(opts, trace.WithAttributes(attrs...))
+			}
+
+			ctx, span := tracer.Start(ctx, "cron.job", opts...)
+			defer span.End()
+			err := cron.RunJob(ctx, j)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package cronotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonaz/cron"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWithTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var inner trace.SpanContext
+	job := WithTracing(tp)(cron.ContextFuncJob(func(ctx context.Context) error {
+		inner = trace.SpanContextFromContext(ctx)
+		return errors.New("failed")
+	}))
+	if err := cron.RunJob(context.Background(), job); err == nil {
+		t.Fatal("expected the job's error to be returned")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].SpanContext().SpanID() != inner.SpanID() {
+		t.Error("expected the job to be handed the span's context")
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("expected an error status, got %v", spans[0].Status().Code)
+	}
+}
+
+func TestWithTracingEntryAttributes(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	clock := cron.NewFakeClock(time.Date(2012, 7, 9, 14, 0, 0, 0, time.Local))
+	c := cron.New(cron.WithClock(clock), cron.WithChain(WithTracing(tp)))
+	ran := make(chan struct{})
+	id, _ := c.AddFunc("* * * * * ?", func() { close(ran) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.Ended()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("span was not ended")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, attr := range recorder.Ended()[0].Attributes() {
+		if attr.Key == "cron.entry.id" {
+			if attr.Value.AsInt64() != id {
+				t.Errorf("expected entry id %d, got %d", id, attr.Value.AsInt64())
+			}
+			return
+		}
+	}
+	t.Error("expected the span to carry the entry id")
+}
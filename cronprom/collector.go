@@ -0,0 +1,165 @@
+// Package cronprom exports metrics about the jobs of a cron.Cron to
+// Prometheus. It is a separate package so that only users of Prometheus depend
+// on it.
+package cronprom
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/jonaz/cron"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// otherJobs is the job label value shared by the jobs beyond the limit set by
+// WithJobLabels.
+const otherJobs = "other"
+
+// Collector is a prometheus.Collector for the jobs of a Cron. It counts runs,
+// errors and skipped runs, and records how long runs take, from the Cron's
+// events; Run must be called to consume them. It also reports the number of
+// entries.
+type Collector struct {
+	cron *cron.Cron
+
+	runs      *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+	skipped   *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+	entries   *prometheus.Desc
+
+	buckets []float64
+	maxJobs int
+
+	mu   sync.Mutex
+	jobs map[int64]string
+}
+
+// Option configures a Collector.
+type Option func(*Collector)
+
+// WithJobLabels labels the metrics with the job id, for up to max distinct
+// jobs; the jobs beyond that share the label value "other", to bound the
+// cardinality. By default the metrics have no job label.
+func WithJobLabels(max int) Option {
+	return func(c *Collector) {
+		c.maxJobs = max
+	}
+}
+
+// WithBuckets sets the buckets, in seconds, of the job duration histogram
+// instead of prometheus.DefBuckets.
+func WithBuckets(buckets []float64) Option {
+	return func(c *Collector) {
+		c.buckets = buckets
+	}
+}
+
+// NewCollector returns a Collector for the jobs of the Cron, modified by the
+// given options.
+func NewCollector(c *cron.Cron, opts ...Option) *Collector {
+	collector := &Collector{
+		cron:    c,
+		buckets: prometheus.DefBuckets,
+		jobs:    make(map[int64]string),
+	}
+	for _, opt := range opts {
+		opt(collector)
+	}
+
+	var labels []string
+	if collector.maxJobs > 0 {
+		labels = []string{"job"}
+	}
+	collector.runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cron_job_runs_total",
+		Help: "Number of job runs started.",
+	}, labels)
+	collector.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cron_job_errors_total",
+		Help: "Number of job runs that returned an error.",
+	}, labels)
+	collector.skipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cron_job_skipped_total",
+		Help: "Number of due job runs skipped because the job or scheduler was paused, the last run was still going with OverlapSkip, or the run was missed.",
+	}, labels)
+	collector.durations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cron_job_duration_seconds",
+		Help:    "How long job runs took.",
+		Buckets: collector.buckets,
+	}, labels)
+	collector.entries = prometheus.NewDesc("cron_entries", "Number of entries.", nil, nil)
+	return collector
+}
+
+// Run consumes the Cron's events until ctx is done. The Cron's Events channel
+// must not be consumed by anything else.
+func (c *Collector) Run(ctx context.Context) {
+	events := c.cron.Events()
+	for {
+		select {
+		case event := <-events:
+			c.observe(event)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// observe updates the metrics for the event.
+func (c *Collector) observe(event cron.Event) {
+	// Events about the scheduler as a whole have no job to count them for.
+	if event.Type == cron.EventIdle || event.Type == cron.EventBusy {
+		return
+	}
+	labels := c.labels(event.ID)
+	switch event.Type {
+	case cron.EventJobStarted:
+		c.runs.WithLabelValues(labels...).Inc()
+	case cron.EventJobErrored:
+		c.errors.WithLabelValues(labels...).Inc()
+		c.durations.WithLabelValues(labels...).Observe(event.Duration.Seconds())
+	case cron.EventJobFinished:
+		c.durations.WithLabelValues(labels...).Observe(event.Duration.Seconds())
+	case cron.EventJobSkipped:
+		c.skipped.WithLabelValues(labels...).Inc()
+	}
+}
+
+// labels returns the label values for the job with the given id.
+func (c *Collector) labels(id int64) []string {
+	if c.maxJobs <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	job, ok := c.jobs[id]
+	if !ok {
+		if len(c.jobs) >= c.maxJobs {
+			return []string{otherJobs}
+		}
+		job = strconv.FormatInt(id, 10)
+		c.jobs[id] = job
+	}
+	return []string{job}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.runs.Describe(ch)
+	c.errors.Describe(ch)
+	c.skipped.Describe(ch)
+	c.durations.Describe(ch)
+	ch <- c.entries
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.runs.Collect(ch)
+	c.errors.Collect(ch)
+	c.skipped.Collect(ch)
+	c.durations.Collect(ch)
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(c.cron.Len()))
+}
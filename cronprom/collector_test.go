@@ -0,0 +1,41 @@
+package cronprom
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jonaz/cron"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	clock := cron.NewFakeClock(time.Date(2012, 7, 9, 14, 0, 0, 0, time.Local))
+	c := cron.New(cron.WithClock(clock))
+	collector := NewCollector(c, WithJobLabels(1))
+	id, _ := c.AddFunc("* * * * * ?", func() {})
+	c.AddJob("* * * * * ?", cron.ErrFuncJob(func() error { return errors.New("failed") }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go collector.Run(ctx)
+	c.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(collector.errors.WithLabelValues(otherJobs)) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected an error to be counted for the second job")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := testutil.ToFloat64(collector.runs.WithLabelValues(strconv.FormatInt(id, 10))); n != 1 {
+		t.Errorf("expected 1 run of the first job, got %v", n)
+	}
+	if n := testutil.CollectAndCount(collector, "cron_entries"); n != 1 {
+		t.Errorf("expected the entry count to be collected, got %d metrics", n)
+	}
+}
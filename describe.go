@@ -0,0 +1,304 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Describe returns a plain English description of the spec, e.g. "At 9:00 AM,
+// Monday through Friday" for "0 0 9 * * MON-FRI". It covers the common cases;
+// exotic specs get a more literal description, field by field. It returns the
+// error Parse would return for an invalid spec.
+func Describe(spec string) (string, error) {
+	if _, err := Parse(spec); err != nil {
+		return "", err
+	}
+	// Parse accepts spaces around and between the fields.
+	spec = strings.Join(strings.Fields(spec), " ")
+
+	var zone string
+	const tzPrefix = "CRON_TZ="
+	if strings.HasPrefix(spec, tzPrefix) {
+		fields := strings.SplitN(spec, " ", 2)
+		zone = " (" + fields[0][len(tzPrefix):] + ")"
+		spec = strings.TrimSpace(fields[1])
+	}
+
+	if spec[0] == '@' {
+		return describeDescriptor(spec) + zone, nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) == 5 {
+		fields = append(fields, "*")
+	}
+	parts := []string{describeTime(fields[0], fields[1], fields[2])}
+	parts = append(parts, describeDays(fields[3], fields[4], fields[5])...)
+	return strings.Join(parts, ", ") + zone, nil
+}
+
+// describeDescriptor describes a spec starting with @.
+func describeDescriptor(spec string) string {
+	switch spec {
+	case "@yearly", "@annually":
+		return "At midnight on January 1"
+	case "@monthly":
+		return "At midnight on day 1 of the month"
+	case "@weekly":
+		return "At midnight on Sunday"
+	case "@daily", "@midnight":
+		return "Every day at midnight"
+	case "@hourly":
+		return "Every hour"
+	case "@reboot":
+		return "Once, when the scheduler starts"
+	}
+
+	const every = "@every "
+	if strings.HasPrefix(spec, every) {
+		var delay time.Duration
+		switch schedule := parseDescriptor(spec).(type) {
+		case PreciseDelaySchedule:
+			delay = schedule.Delay
+		case ConstantDelaySchedule:
+			delay = schedule.Delay
+		}
+		return "Every " + delay.String()
+	}
+
+	fields := strings.Fields(spec)
+	for len(fields) < 4 {
+		fields = append(fields, "*")
+	}
+	token, offset, _ := splitSunOffset(fields[0][1:])
+	state, twilight, _ := splitSunState(token)
+	event := map[string]string{
+		"sunset":     "sunset",
+		"sunrise":    "sunrise",
+		"dusk":       "dusk",
+		"dawn":       "dawn",
+		"solarnoon":  "solar noon",
+		"goldenhour": "golden hour",
+	}[state]
+	if _, ok := twilights[twilight]; ok && twilight != defaultTwilight {
+		event = twilight + " " + event
+	} else if twilight != "" && !ok {
+		event += " at " + twilight + " degrees sun elevation"
+	}
+	switch {
+	case offset > 0:
+		event = offset.String() + " after " + event
+	case offset < 0:
+		event = (-offset).String() + " before " + event
+	}
+	return strings.Join(append([]string{"At " + event}, describeDays(fields[1], fields[2], fields[3])...), ", ")
+}
+
+// describeTime describes the second, minute and hour fields.
+func describeTime(second, minute, hour string) string {
+	s, sok := singleValue(second)
+	m, mok := singleValue(minute)
+	h, hok := singleValue(hour)
+
+	switch {
+	case sok && mok && hok:
+		return "At " + clockTime(h, m, s)
+	case second == "0" && mok && hour == "*":
+		if m == 0 {
+			return "Every hour"
+		}
+		return fmt.Sprintf("At minute %d past every hour", m)
+	case second == "0" && mok:
+		return fmt.Sprintf("At minute %d past %s", m, describeField(hour, "hour", nil))
+	}
+
+	var parts []string
+	if second != "0" {
+		parts = append(parts, describeField(second, "second", nil))
+	}
+	if minute != "*" || second == "0" {
+		parts = append(parts, describeField(minute, "minute", nil))
+	}
+	if hour != "*" {
+		parts = append(parts, describeField(hour, "hour", nil))
+	}
+	return capitalize(strings.Join(parts, ", "))
+}
+
+// describeDays describes the day of month, month and day of week fields,
+// returning a part for each restricted field.
+func describeDays(dom, month, dow string) []string {
+	var parts []string
+	domSet := dom != "*" && dom != "?"
+	dowSet := dow != "*" && dow != "?"
+	switch {
+	case domSet && dowSet:
+		parts = append(parts, describeDom(dom)+" or on "+describeNames(dow, "day", weekdayName))
+	case domSet:
+		parts = append(parts, describeDom(dom))
+	case dowSet:
+		parts = append(parts, describeNames(dow, "day", weekdayName))
+	}
+	if month != "*" {
+		parts = append(parts, "in "+describeNames(month, "month", monthName))
+	}
+	return parts
+}
+
+// describeDom describes the day of month field.
+func describeDom(field string) string {
+	if !strings.Contains(field, ",") && strings.HasSuffix(strings.ToUpper(field), "W") {
+		return "on the weekday nearest day " + field[:len(field)-1] + " of the month"
+	}
+	elements := strings.Split(field, ",")
+	for _, expr := range elements {
+		if !strings.HasPrefix(expr, "-") {
+			continue
+		}
+		// Describe the elements one by one, as there are days counted
+		// from the end of the month among them.
+		var parts []string
+		for _, expr := range elements {
+			if strings.HasPrefix(expr, "-") {
+				parts = append(parts, fromMonthEndName(expr[1:]))
+				continue
+			}
+			element, _ := describeElement(expr, "day", nil)
+			parts = append(parts, "day "+element)
+		}
+		return "on " + joinList(parts) + " of the month"
+	}
+	return "on " + describeField(field, "day", nil) + " of the month"
+}
+
+// fromMonthEndName names the nth day counted back from the end of the month,
+// e.g. "the last day" for 1 and "the 2nd-to-last day" for 2.
+func fromMonthEndName(n string) string {
+	if n == "1" {
+		return "the last day"
+	}
+	suffix := "th"
+	switch v, _ := strconv.Atoi(n); {
+	case v%100 >= 11 && v%100 <= 13:
+	case v%10 == 1:
+		suffix = "st"
+	case v%10 == 2:
+		suffix = "nd"
+	case v%10 == 3:
+		suffix = "rd"
+	}
+	return "the " + n + suffix + "-to-last day"
+}
+
+// describeField describes a numeric field, e.g. "every 15 minutes" or
+// "minutes 5 through 10".
+func describeField(field, unit string, name func(uint) string) string {
+	if field == "*" || field == "?" {
+		return "every " + unit
+	}
+
+	var (
+		elements []string
+		plural   bool
+	)
+	for _, expr := range strings.Split(field, ",") {
+		element, isPlural := describeElement(expr, unit, name)
+		elements = append(elements, element)
+		plural = plural || isPlural
+	}
+	if len(elements) == 1 && strings.HasPrefix(elements[0], "every ") {
+		return elements[0]
+	}
+	if plural || len(elements) > 1 {
+		unit += "s"
+	}
+	return unit + " " + joinList(elements)
+}
+
+// describeNames describes a field whose values have names, e.g. "Monday
+// through Friday".
+func describeNames(field, unit string, name func(uint) string) string {
+	var elements []string
+	for _, expr := range strings.Split(field, ",") {
+		element, _ := describeElement(expr, unit, name)
+		elements = append(elements, element)
+	}
+	return joinList(elements)
+}
+
+// describeElement describes one element of a comma-separated field, and
+// reports whether it refers to more than one value.
+func describeElement(expr, unit string, name func(uint) string) (string, bool) {
+	value := func(s string) string {
+		if name == nil {
+			return s
+		}
+		return name(parseIntOrName(s, allNames))
+	}
+
+	rangeAndStep := strings.Split(expr, "/")
+	if len(rangeAndStep) == 2 {
+		step, from := rangeAndStep[1], rangeAndStep[0]
+		every := "every " + step + " " + unit + "s"
+		switch lowAndHigh := strings.Split(from, "-"); {
+		case from == "*" || from == "?":
+			return every, true
+		case len(lowAndHigh) == 2:
+			return every + " from " + value(lowAndHigh[0]) + " through " + value(lowAndHigh[1]), true
+		default:
+			return every + " from " + value(from), true
+		}
+	}
+
+	if lowAndHigh := strings.Split(expr, "-"); len(lowAndHigh) == 2 {
+		return value(lowAndHigh[0]) + " through " + value(lowAndHigh[1]), true
+	}
+	return value(expr), false
+}
+
+// allNames maps the names of both months and weekdays to their values.
+var allNames = func() map[string]uint {
+	names := map[string]uint{}
+	for k, v := range months.names {
+		names[k] = v
+	}
+	for k, v := range dow.names {
+		names[k] = v
+	}
+	return names
+}()
+
+func weekdayName(v uint) string { return time.Weekday(v % 7).String() }
+func monthName(v uint) string   { return time.Month(v).String() }
+
+// singleValue returns the number in field, if it is a single number.
+func singleValue(field string) (int, bool) {
+	v, err := strconv.Atoi(field)
+	return v, err == nil
+}
+
+// clockTime formats a time of day on a 12-hour clock, e.g. "9:00 AM".
+func clockTime(hour, minute, second int) string {
+	t := time.Date(0, 1, 1, hour, minute, second, 0, time.UTC)
+	if second != 0 {
+		return t.Format("3:04:05 PM")
+	}
+	return t.Format("3:04 PM")
+}
+
+// joinList joins the elements as an English list, e.g. "a, b and c".
+func joinList(elements []string) string {
+	if len(elements) == 1 {
+		return elements[0]
+	}
+	return strings.Join(elements[:len(elements)-1], ", ") + " and " + elements[len(elements)-1]
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
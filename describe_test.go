@@ -0,0 +1,63 @@
+package cron
+
+import "testing"
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		spec, expected string
+	}{
+		{"0 0 9 * * MON-FRI", "At 9:00 AM, Monday through Friday"},
+		{"30 15 14 * * *", "At 2:15:30 PM"},
+		{"0 0 0 1 * ?", "At 12:00 AM, on day 1 of the month"},
+		{"0 0/15 * * * *", "Every 15 minutes from 0"},
+		{"0 */15 * * * *", "Every 15 minutes"},
+		{"0 */15 9-17 * * *", "Every 15 minutes, hours 9 through 17"},
+		{"0 0 * * * *", "Every hour"},
+		{"0 30 * * * *", "At minute 30 past every hour"},
+		{"0 0 9-17 * * *", "At minute 0 past hours 9 through 17"},
+		{"* * * * * *", "Every second"},
+		{"*/5 * * * * *", "Every 5 seconds"},
+		{"0 0 12 * * 1,3,5", "At 12:00 PM, Monday, Wednesday and Friday"},
+		{"0 0 8 * Jul Sun", "At 8:00 AM, Sunday, in July"},
+		{"0 0 8 * 1-3 7", "At 8:00 AM, Sunday, in January through March"},
+		{"0 0 8 13 * 5", "At 8:00 AM, on day 13 of the month or on Friday"},
+		{"0 0 8 15W * ?", "At 8:00 AM, on the weekday nearest day 15 of the month"},
+		{"0 0 8 -1 * ?", "At 8:00 AM, on the last day of the month"},
+		{"0 0 8 1,-2 * ?", "At 8:00 AM, on day 1 and the 2nd-to-last day of the month"},
+		{"0 0 8 1,15 * *", "At 8:00 AM, on days 1 and 15 of the month"},
+		{"@daily", "Every day at midnight"},
+		{"@hourly", "Every hour"},
+		{" @daily", "Every day at midnight"},
+		{"\t@hourly", "Every hour"},
+		{"@daily ", "Every day at midnight"},
+		{"  0 0 9 * * MON-FRI ", "At 9:00 AM, Monday through Friday"},
+		{"CRON_TZ=UTC  @daily", "Every day at midnight (UTC)"},
+		{"@every 1h30m", "Every 1h30m0s"},
+		{"@every 250ms", "Every 250ms"},
+		{"@sunset * * MON-FRI", "At sunset, Monday through Friday"},
+		{"@dusk:nautical", "At nautical dusk"},
+		{"@dawn:-4", "At dawn at -4 degrees sun elevation"},
+		{"@sunset+20m * * MON-FRI", "At 20m0s after sunset, Monday through Friday"},
+		{"@dawn:nautical-1h", "At 1h0m0s before nautical dawn"},
+		{"CRON_TZ=UTC 0 0 9 * * *", "At 9:00 AM (UTC)"},
+	}
+
+	for _, c := range tests {
+		actual, err := Describe(c.spec)
+		if err != nil {
+			t.Errorf("%s: %s", c.spec, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.spec, c.expected, actual)
+		}
+	}
+}
+
+func TestDescribeErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * *", "0 0 25 * * *", "@fortnightly"} {
+		if _, err := Describe(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -20,7 +20,7 @@ them in their own goroutines.
 	// Inspect the cron job entries' next and previous run times.
 	inspect(c.Entries())
 	..
-	c.Stop()  // Stop the scheduler (does not stop any jobs already running).
+	c.Stop()  // Stop the scheduler, waiting for the jobs already running to return.
 
 CRON Expression Format
 
@@ -31,13 +31,33 @@ A cron expression represents a set of times, using 6 space-separated fields.
 	Seconds      | Yes        | 0-59            | * / , -
 	Minutes      | Yes        | 0-59            | * / , -
 	Hours        | Yes        | 0-23            | * / , -
-	Day of month | Yes        | 1-31            | * / , - ?
+	Day of month | Yes        | 1-31            | * / , - ? W
 	Month        | Yes        | 1-12 or JAN-DEC | * / , -
-	Day of week  | Yes        | 0-6 or SUN-SAT  | * / , - ?
+	Day of week  | Yes        | 0-7 or SUN-SAT  | * / , - ?
+
+The number of fields determines how a spec is interpreted:
+
+	6 fields: second minute hour day-of-month month day-of-week
+	5 fields: second minute hour day-of-month month (day-of-week is *)
+
+A Cron created with the WithoutSeconds option instead expects standard 5-field
+crontab specs, which start with the minute; jobs then run at second 0:
+
+	5 fields: minute hour day-of-month month day-of-week
+
+ParseStandard and AddFuncStandard take standard specs whatever the option.
+
+A Cron created with the WithAutoSeconds option, or ParseAuto, takes both: specs
+of 5 fields are standard crontab specs, and specs of 6 fields start with the
+second. Beware that a spec starting with the second that leaves out the day of
+week, e.g. "30 0 9 * *" for 9:00:30 every day, is then read as a standard spec,
+for 0:30 on the 9th of every month.
 
 Note: Month and Day-of-week field values are case insensitive.  "SUN", "Sun",
 and "sun" are equally accepted.
 
+In the Day-of-week field, both 0 and 7 are Sunday, as in standard crontabs.
+
 Special Characters
 
 Asterisk ( * )
@@ -65,10 +85,36 @@ Hyphen ( - )
 Hyphens are used to define ranges. For example, 9-17 would indicate every
 hour between 9am and 5pm inclusive.
 
+In the seconds, minutes, hours and day-of-week fields, a range may wrap around
+the end of the field. For example, 22-2 in the hours field would indicate 10pm,
+11pm, midnight, 1am and 2am, and FRI-MON would indicate Friday through Monday.
+
 Question mark ( ? )
 
 Question mark may be used instead of '*' for leaving either day-of-month or
-day-of-week blank.
+day-of-week blank. It is not allowed in the other fields.
+
+W ( W )
+
+In the day-of-month field, W after a day selects the weekday nearest it, without
+crossing into an adjacent month. For example, 15W would indicate the 14th if the
+15th is a Saturday, the 16th if it is a Sunday, and the 15th otherwise; 1W on a
+Saturday would indicate Monday the 3rd. It is only allowed after a single day.
+
+Negative days of month
+
+In the day-of-month field, a negative day counts back from the end of the
+month: -1 is the last day, whether that is the 28th, 29th, 30th or 31st, and -2
+the day before it. Negative days go down to -31, and may be listed with others,
+e.g. 1,-1 for the first and last day of every month, but not used in ranges.
+
+Day of month and day of week
+
+If both the day-of-month and day-of-week fields are restricted, i.e. neither is
+'*' or '?', a day matches if either of them does, as in standard cron. For
+example, "0 0 0 13 * FRI" would indicate every 13th and every Friday. A Cron
+created with the WithDayAnd option requires both to match instead, which would
+indicate Friday the 13th.
 
 Predefined schedules
 
@@ -81,6 +127,24 @@ You may use one of several pre-defined schedules in place of a cron expression.
 	@weekly                | Run once a week, midnight on Sunday        | 0 0 0 * * 0
 	@daily (or @midnight)  | Run once a day, midnight                   | 0 0 0 * * *
 	@hourly                | Run once an hour, beginning of hour        | 0 0 * * * *
+	@reboot                | Run once, when the scheduler starts        |
+
+Sun schedules
+
+A job may also run at a sun event, at the coordinates set by SetDefaultLocation:
+
+	@<state>[:<twilight>][<offset>] [<day of month> [<month> [<day of week>]]]
+
+where state is one of sunset, sunrise, dusk, dawn, solarnoon and goldenhour.
+Dusk and dawn take a twilight level, one of civil (the default), nautical and
+astronomical, or a sun elevation in degrees, negative below the horizon, e.g.
+"@dusk:-4" for when the sun has set to 4 degrees below it. The offset, e.g.
+"+20m" or "-1h", moves the event. For example, "@sunset+20m * * MON-FRI" would
+indicate 20 minutes after sunset on weekdays.
+
+Near the poles, some days have no sunset or sunrise. A SunSchedule given a
+fallback by its WithFallback method skips those days, or runs at a fixed time
+on them, instead.
 
 Intervals
 
@@ -93,7 +157,13 @@ where "duration" is a string accepted by time.ParseDuration
 (http://golang.org/pkg/time/#ParseDuration).
 
 For example, "@every 1h30m10s" would indicate a schedule that activates every
-1 hour, 30 minutes, 10 seconds.
+1 hour, 30 minutes, 10 seconds. The first run is one interval after the
+scheduler starts, or after the job is added to a running one.
+
+Durations are truncated to whole seconds, e.g. "@every 1500ms" activates every
+second, except for those of less than a second, e.g. "@every 250ms", which are
+kept as they are. Scheduler overhead puts the practical floor for those at a few
+milliseconds. Durations must be positive.
 
 Note: The interval does not take the job runtime into account.  For example,
 if a job takes 3 minutes to run, and it is scheduled to run every 5 minutes,
@@ -104,9 +174,18 @@ Time zones
 All interpretation and scheduling is done in the machine's local time zone (as
 provided by the Go time package (http://www.golang.org/pkg/time).
 
+A schedule may be given its own time zone by prefixing the spec with
+"CRON_TZ=" and the name of an IANA time zone, as accepted by time.LoadLocation:
+
+	CRON_TZ=America/New_York 0 0 9 * * *
+
 Be aware that jobs scheduled during daylight-savings leap-ahead transitions will
 not be run!
 
+When the clock is set back, e.g. by NTP, entries are rescheduled from the time
+the clock reads once the scheduler notices, so runs of the time it was set back
+over happen again. Set WithWakeInterval for the scheduler to notice soon.
+
 Thread safety
 
 Since the Cron service runs concurrently with the calling code, some amount of
@@ -114,16 +193,18 @@ care must be taken to ensure proper synchronization.
 
 All cron methods are designed to be correctly synchronized as long as the caller
 ensures that invocations have a clear happens-before ordering between them.
+Entries may also be added and inspected from several goroutines at once, before
+Start as well as after.
 
 Implementation
 
-Cron entries are stored in an array, sorted by their next activation time.  Cron
+Cron entries are stored in a heap, ordered by their next activation time.  Cron
 sleeps until the next job is due to be run.
 
 Upon waking:
- - it runs each entry that is active on that second
+ - it pops each entry that is active on that second, and runs it
  - it calculates the next run times for the jobs that were run
- - it re-sorts the array of entries by next activation time.
+ - it pushes them back onto the heap.
  - it goes to sleep until the soonest job.
 */
 package cron
@@ -0,0 +1,112 @@
+package cron
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	// EventJobStarted is published when an entry's job is dispatched.
+	EventJobStarted EventType = iota
+
+	// EventJobFinished is published when a job returns without an error.
+	EventJobFinished
+
+	// EventJobErrored is published when an ErrJob returns an error.
+	EventJobErrored
+
+	// EventJobSkipped is published when an entry is due, but its job is not
+	// run: because the entry or the whole scheduler is paused, because its
+	// last run is still going with OverlapSkip, or because the activation was
+	// missed, e.g. while the machine was asleep, without WithCatchUp.
+	EventJobSkipped
+
+	// EventIdle is published when the scheduler has no entry with a next run
+	// time left to wait for, because there are none or none is satisfiable.
+	EventIdle
+
+	// EventBusy is published when the scheduler has an entry to wait for
+	// again after being idle.
+	EventBusy
+
+	// EventJobOverran is published, after EventJobFinished or EventJobErrored,
+	// when a job ran for longer than the interval to its following run, or
+	// the share of it set with WithOverrunThreshold. It warns that runs will
+	// overlap or drift.
+	EventJobOverran
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventJobStarted:
+		return "started"
+	case EventJobFinished:
+		return "finished"
+	case EventJobErrored:
+		return "errored"
+	case EventJobSkipped:
+		return "skipped"
+	case EventIdle:
+		return "idle"
+	case EventBusy:
+		return "busy"
+	case EventJobOverran:
+		return "overran"
+	}
+	return "unknown"
+}
+
+// Event describes something that happened to an entry's job, or to the
+// scheduler as a whole.
+type Event struct {
+	// The id of the entry, or 0 for EventIdle and EventBusy.
+	ID int64
+
+	// What happened.
+	Type EventType
+
+	// When it happened.
+	Time time.Time
+
+	// The error returned by the job, for EventJobErrored.
+	Err error
+
+	// How long the job ran, for EventJobFinished, EventJobErrored and
+	// EventJobOverran.
+	Duration time.Duration
+
+	// The interval from the time the run was due to the following run, for
+	// EventJobOverran.
+	Interval time.Duration
+
+	// The id of the run, see RunIDFromContext, for EventJobStarted,
+	// EventJobFinished, EventJobErrored and EventJobOverran.
+	RunID int64
+}
+
+// eventBufferSize is the number of events buffered for a slow consumer.
+const eventBufferSize = 100
+
+// Events returns a channel on which the run loop publishes job events. Events
+// are only published once Events has been called. The channel is buffered; if
+// the consumer falls behind and the buffer is full, new events are dropped
+// rather than stalling the scheduler.
+func (c *Cron) Events() <-chan Event {
+	atomic.StoreInt32(&c.subscribed, 1)
+	return c.events
+}
+
+// publish sends the event to the Events channel, unless nobody subscribed or
+// the buffer is full.
+func (c *Cron) publish(event Event) {
+	if atomic.LoadInt32(&c.subscribed) == 0 {
+		return
+	}
+	select {
+	case c.events <- event:
+	default:
+	}
+}
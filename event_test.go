@@ -0,0 +1,191 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	ok, _ := cron.AddFunc("* * * * * ?", func() {})
+	failing, _ := cron.AddJob("* * * * * ?", ErrFuncJob(func() error { return errors.New("failed") }))
+	paused, _ := cron.AddFunc("* * * * * ?", func() {})
+	cron.PauseFunc(paused)
+
+	events := cron.Events()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	expected := map[int64][]EventType{
+		ok:      {EventJobStarted, EventJobFinished},
+		failing: {EventJobStarted, EventJobErrored},
+		paused:  {EventJobSkipped},
+	}
+	actual := map[int64][]EventType{}
+	for i := 0; i < 5; i++ {
+		select {
+		case event := <-events:
+			actual[event.ID] = append(actual[event.ID], event.Type)
+			if event.Type == EventJobErrored && event.Err == nil {
+				t.Error("errored event without an error")
+			}
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("timed out waiting for events, got %v", actual)
+		}
+	}
+
+	for id, types := range expected {
+		if len(actual[id]) != len(types) {
+			t.Errorf("entry %d: (expected) %v != %v (actual)", id, types, actual[id])
+			continue
+		}
+		for i := range types {
+			if actual[id][i] != types[i] {
+				t.Errorf("entry %d: (expected) %v != %v (actual)", id, types, actual[id])
+			}
+		}
+	}
+}
+
+// Test that the events and log lines of a run carry the id the job gets from
+// its context, and that each run gets its own.
+func TestRunIDs(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	lines := make(logLines, 10)
+	cron := New(WithClock(clock), WithRunLogger(log.New(lines, "", 0)))
+	fromCtx := make(chan int64, 2)
+	id, _ := cron.AddJob("* * * * * ?", ContextFuncJob(func(ctx context.Context) error {
+		runID, _ := RunIDFromContext(ctx)
+		fromCtx <- runID
+		return nil
+	}))
+	events := cron.Events()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	seen := map[int64]bool{}
+	for run := 0; run < 2; run++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		var started, finished Event
+		for _, event := range []*Event{&started, &finished} {
+			select {
+			case *event = <-events:
+			case <-time.After(ONE_SECOND):
+				t.Fatal("timed out waiting for events")
+			}
+		}
+		if started.Type != EventJobStarted || finished.Type != EventJobFinished {
+			t.Fatalf("unexpected events %v, %v", started.Type, finished.Type)
+		}
+		runID := <-fromCtx
+		if started.RunID != runID || finished.RunID != runID {
+			t.Errorf("expected the events to carry run id %d, got %d and %d", runID, started.RunID, finished.RunID)
+		}
+		if runID == 0 || seen[runID] {
+			t.Errorf("expected a new run id, got %d", runID)
+		}
+		seen[runID] = true
+		for _, prefix := range []string{"started", "finished"} {
+			expected := fmt.Sprintf("cron: run %d of entry %d %s", runID, id, prefix)
+			if line := <-lines; !strings.HasPrefix(line, expected) {
+				t.Errorf("(expected) %q != %q (actual)", expected, line)
+			}
+		}
+	}
+	if _, ok := RunIDFromContext(context.Background()); ok {
+		t.Error("expected no run id outside of a run")
+	}
+}
+
+// Test that a consumer that doesn't keep up doesn't stall the scheduler.
+func TestEventsDropped(t *testing.T) {
+	cron := New()
+	cron.Events()
+	for i := 0; i < 2*eventBufferSize; i++ {
+		cron.publish(Event{ID: int64(i)})
+	}
+	if n := len(cron.events); n != eventBufferSize {
+		t.Errorf("(expected) %d != %d (actual)", eventBufferSize, n)
+	}
+}
+
+// Test that the scheduler reports going idle when it has nothing to wait for,
+// and busy again when an entry is added.
+func TestIdleEvents(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	cron := New(WithClock(clock))
+	cron.AddFunc("0 0 0 30 Feb ?", func() {})
+	events := cron.Events()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	expect := func(expected EventType) {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Type != expected || event.ID != 0 {
+				t.Errorf("expected an %v event, got %+v", expected, event)
+			}
+		case <-time.After(ONE_SECOND):
+			t.Fatalf("timed out waiting for an %v event", expected)
+		}
+	}
+	expect(EventIdle)
+	id, _ := cron.AddFunc("@hourly", func() {})
+	expect(EventBusy)
+	cron.RemoveJob(id)
+	expect(EventIdle)
+}
+
+// Test that runs taking longer than the threshold share of their interval are
+// reported.
+func TestOverrunEvents(t *testing.T) {
+	for _, c := range []struct {
+		threshold float64
+		overran   bool
+	}{
+		{1, false},
+		{0.8, true},
+	} {
+		clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+		cron := New(WithClock(clock), WithOverrunThreshold(c.threshold))
+		id, _ := cron.AddFunc("* * * * * ?", func() { clock.Advance(900 * time.Millisecond) })
+		events := cron.Events()
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+
+		var overran *Event
+		for done := false; !done; {
+			select {
+			case event := <-events:
+				if event.Type == EventJobOverran {
+					overran = &event
+				}
+			case <-time.After(50 * time.Millisecond):
+				done = true
+			}
+		}
+		cancel()
+		if (overran != nil) != c.overran {
+			t.Errorf("threshold %v: expected overran %v, got %+v", c.threshold, c.overran, overran)
+			continue
+		}
+		if overran != nil && (overran.ID != id || overran.Duration != 900*time.Millisecond || overran.Interval != time.Second) {
+			t.Errorf("threshold %v: unexpected event %+v", c.threshold, overran)
+		}
+	}
+}
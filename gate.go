@@ -0,0 +1,68 @@
+package cron
+
+import (
+	"sync"
+	"time"
+)
+
+// gateInterval is how often a Cron checks the gate of a GatedBy schedule that
+// is not open yet.
+const gateInterval = time.Second
+
+// gatedSchedule activates as inner does once ready first returns true. Until
+// then, it hands out probes: activation times at which the Cron only checks the
+// gate again, without running the job.
+type gatedSchedule struct {
+	ready func() bool
+	inner Schedule
+
+	mu   sync.Mutex
+	open bool
+}
+
+// GatedBy returns a Schedule that activates as inner does, but only from the
+// first time ready returns true; jobs on it don't run before then. Until it
+// does, a Cron calls ready about once a second, so it must return quickly. Once
+// it has returned true, it is not called again. The gate works as well with
+// the schedule wrapped, e.g. by AnyOf or AlignTo.
+func GatedBy(ready func() bool, inner Schedule) Schedule {
+	return &gatedSchedule{ready: ready, inner: inner}
+}
+
+// Next returns the next activation time of inner if the gate is open, and a
+// probe on the second after t otherwise.
+func (s *gatedSchedule) Next(t time.Time) time.Time {
+	next, _ := s.nextProbe(t)
+	return next
+}
+
+// nextProbe is like Next, but also reports whether the time is a probe.
+func (s *gatedSchedule) nextProbe(t time.Time) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open && s.ready() {
+		s.open = true
+	}
+	if s.open {
+		return nextProbe(s.inner, t)
+	}
+	return t.Add(gateInterval - time.Duration(t.Nanosecond())), true
+}
+
+// probingSchedule is a Schedule some of whose activation times may be probes,
+// at which the Cron only checks the gate of a GatedBy schedule, without running
+// the job. The wrappers of this package implement it, passing on the probes of
+// the schedules they wrap.
+type probingSchedule interface {
+	Schedule
+	nextProbe(t time.Time) (time.Time, bool)
+}
+
+// nextProbe returns the next activation time of the schedule after t, and
+// whether it is a probe.
+func nextProbe(schedule Schedule, t time.Time) (time.Time, bool) {
+	if s, ok := schedule.(probingSchedule); ok {
+		return s.nextProbe(t)
+	}
+	return schedule.Next(t), false
+}
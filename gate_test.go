@@ -0,0 +1,110 @@
+package cron
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGatedByNext(t *testing.T) {
+	var ready int32
+	s := GatedBy(func() bool { return atomic.LoadInt32(&ready) == 1 }, Every(time.Hour))
+	start := getTime("Mon Jul 9 14:00:00 2012")
+	if next := s.Next(start.Add(500 * time.Millisecond)); !next.Equal(start.Add(time.Second)) {
+		t.Errorf("expected a probe on the next second, got %v", next)
+	}
+	atomic.StoreInt32(&ready, 1)
+	if next := s.Next(start); !next.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected the inner schedule once ready, got %v", next)
+	}
+	atomic.StoreInt32(&ready, 0)
+	if next := s.Next(start); !next.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected the gate to stay open, got %v", next)
+	}
+}
+
+// Test that a job on a gated schedule doesn't run until the gate opens, and
+// then runs on the inner schedule.
+func TestGatedBy(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	var ready, runs int32
+	cron := New(WithClock(clock))
+	minutely, _ := Parse("0 * * * * ?")
+	id := cron.AddSchedule(GatedBy(func() bool { return atomic.LoadInt32(&ready) == 1 }, minutely),
+		FuncJob(func() { atomic.AddInt32(&runs, 1) }))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	for i := 0; i < 30; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	if entry, _ := cron.EntryByID(id); entry.RunCount != 0 || !entry.Prev.IsZero() {
+		t.Fatalf("expected no run before the gate opened, got %+v", entry)
+	}
+
+	atomic.StoreInt32(&ready, 1)
+	for i := 0; i < 60; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	entry := waitForEntry(t, cron, id, func(e Entry) bool { return atomic.LoadInt32(&runs) == 1 })
+	if expected := getTime("Mon Jul 9 14:01 2012").Local(); entry.RunCount != 1 || !entry.Prev.Equal(expected) {
+		t.Errorf("expected a single run at %v, got %d runs, the last at %v", expected, entry.RunCount, entry.Prev)
+	}
+	if expected := getTime("Mon Jul 9 14:02 2012").Local(); !entry.Next.Equal(expected) {
+		t.Errorf("expected the next run at %v, got %v", expected, entry.Next)
+	}
+}
+
+// Test that a gated schedule wrapped in another one doesn't run its job at the
+// probes either.
+func TestGatedByWrapped(t *testing.T) {
+	wrappers := []struct {
+		name string
+		wrap func(Schedule) Schedule
+	}{
+		{"AnyOf", func(s Schedule) Schedule { return AnyOf(s) }},
+		{"AlignTo", func(s Schedule) Schedule { return AlignTo(time.Second, s) }},
+		{"WithJitter", func(s Schedule) Schedule {
+			return WithJitterSource(time.Millisecond, s, rand.NewSource(1))
+		}},
+		{"CRON_TZ", func(s Schedule) Schedule { return &locationSchedule{s, time.UTC} }},
+	}
+	for _, w := range wrappers {
+		t.Run(w.name, func(t *testing.T) {
+			clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+			var ready, runs int32
+			cron := New(WithClock(clock))
+			minutely, _ := Parse("0 * * * * ?")
+			gated := GatedBy(func() bool { return atomic.LoadInt32(&ready) == 1 }, minutely)
+			id := cron.AddSchedule(w.wrap(gated), FuncJob(func() { atomic.AddInt32(&runs, 1) }))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cron.Start(ctx)
+
+			for i := 0; i < 30; i++ {
+				clock.BlockUntil(1)
+				clock.Advance(time.Second)
+				cron.Health()
+				cron.IsSatisfiable(id)
+			}
+			if entry, _ := cron.EntryByID(id); entry.RunCount != 0 || !entry.Prev.IsZero() {
+				t.Fatalf("expected no run before the gate opened, got %+v", entry)
+			}
+
+			atomic.StoreInt32(&ready, 1)
+			for i := 0; i < 60; i++ {
+				clock.BlockUntil(1)
+				clock.Advance(time.Second)
+			}
+			entry := waitForEntry(t, cron, id, func(e Entry) bool { return atomic.LoadInt32(&runs) == 1 })
+			if entry.RunCount != 1 {
+				t.Errorf("expected a single run once the gate opened, got %d", entry.RunCount)
+			}
+		})
+	}
+}
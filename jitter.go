@@ -0,0 +1,86 @@
+package cron
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// jitterSchedule delays every activation of the wrapped schedule by a random
+// offset, so that jobs sharing a nominal slot don't all start at once.
+type jitterSchedule struct {
+	max   time.Duration
+	inner Schedule
+
+	// Whether the source was given to WithJitterSource. If not, a Cron the
+	// schedule is added to draws the offsets from its own source instead.
+	seeded bool
+	rand   *lockedRand
+}
+
+// lockedRand is a rand.Rand that is safe for concurrent use.
+type lockedRand struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newLockedRand(r *rand.Rand) *lockedRand {
+	return &lockedRand{rand: r}
+}
+
+func (r *lockedRand) Int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Int63n(n)
+}
+
+// WithJitter returns a Schedule that adds a random offset in [0, max) to each
+// activation time of inner.  Each wrapper has its own time-seeded source, but
+// draws from the Cron's source, see WithRand, once added to one.
+//
+// Note that jitter may push a run past its nominal slot, and a max larger than
+// the interval of inner will cause some activations to be skipped.
+func WithJitter(max time.Duration, inner Schedule) Schedule {
+	return &jitterSchedule{
+		max:   max,
+		inner: inner,
+		rand:  newLockedRand(rand.New(rand.NewSource(time.Now().UnixNano()))),
+	}
+}
+
+// WithJitterSource is like WithJitter, but draws the offsets from src.  Given
+// the same source, the sequence of activation times is deterministic.
+func WithJitterSource(max time.Duration, inner Schedule, src rand.Source) Schedule {
+	return &jitterSchedule{
+		max:    max,
+		inner:  inner,
+		seeded: true,
+		rand:   newLockedRand(rand.New(src)),
+	}
+}
+
+// Next returns the next activation time of the wrapped schedule, plus jitter.
+// An unsatisfiable (zero) time is returned unchanged.
+func (s *jitterSchedule) Next(t time.Time) time.Time {
+	next, _ := s.nextProbe(t)
+	return next
+}
+
+// nextProbe is like Next, but also reports whether the time is a probe, see
+// GatedBy. Probes get no jitter.
+func (s *jitterSchedule) nextProbe(t time.Time) (time.Time, bool) {
+	next, probe := nextProbe(s.inner, t)
+	if next.IsZero() || probe || s.max <= 0 {
+		return next, probe
+	}
+	return next.Add(time.Duration(s.rand.Int63n(int64(s.max)))), false
+}
+
+// withRand returns the schedule drawing from r, if it is a jitter schedule
+// without a source of its own.
+func withRand(schedule Schedule, r *lockedRand) Schedule {
+	if s, ok := schedule.(*jitterSchedule); ok && !s.seeded {
+		return &jitterSchedule{max: s.max, inner: s.inner, seeded: true, rand: r}
+	}
+	return schedule
+}
@@ -0,0 +1,73 @@
+package cron
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestJitterWithinBounds(t *testing.T) {
+	inner := Every(time.Hour)
+	sched := WithJitterSource(time.Minute, inner, rand.NewSource(1))
+
+	start := getTime("Mon Jul 9 14:00 2012")
+	for i := 0; i < 100; i++ {
+		nominal := inner.Next(start)
+		actual := sched.Next(start)
+		if actual.Before(nominal) || !actual.Before(nominal.Add(time.Minute)) {
+			t.Fatalf("%v: jittered time %v outside [%v, %v)", start, actual, nominal, nominal.Add(time.Minute))
+		}
+	}
+}
+
+func TestJitterDeterministic(t *testing.T) {
+	a := WithJitterSource(time.Minute, Every(time.Hour), rand.NewSource(42))
+	b := WithJitterSource(time.Minute, Every(time.Hour), rand.NewSource(42))
+
+	start := getTime("Mon Jul 9 14:00 2012")
+	for i := 0; i < 10; i++ {
+		if ta, tb := a.Next(start), b.Next(start); ta != tb {
+			t.Fatalf("same source produced different times: %v != %v", ta, tb)
+		}
+	}
+}
+
+func TestJitterUnsatisfiable(t *testing.T) {
+	inner, _ := Parse("0 0 0 30 Feb ?")
+	sched := WithJitterSource(time.Minute, inner, rand.NewSource(1))
+	if actual := sched.Next(getTime("Mon Jul 9 14:00 2012")); !actual.IsZero() {
+		t.Errorf("expected zero time, got %v", actual)
+	}
+}
+
+// Test that jitter schedules added to a Cron draw from the source given with
+// WithRand, while those with a source of their own keep it.
+func TestWithRand(t *testing.T) {
+	const max = time.Minute
+	start := getTime("Mon Jul 9 14:00 2012").Local()
+	clock := NewFakeClock(start)
+	cron := New(WithClock(clock), WithRand(rand.New(rand.NewSource(1))))
+	cron.Schedule(WithJitter(max, Every(time.Hour)), FuncJob(func() {}), 1)
+	cron.Schedule(WithJitter(max, Every(2*time.Hour)), FuncJob(func() {}), 2)
+	cron.Schedule(WithJitterSource(max, Every(3*time.Hour), rand.NewSource(2)), FuncJob(func() {}), 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	var (
+		cronRand = rand.New(rand.NewSource(1))
+		own      = rand.New(rand.NewSource(2))
+		first    = time.Duration(cronRand.Int63n(int64(max)))
+		second   = time.Duration(cronRand.Int63n(int64(max)))
+	)
+	for id, expected := range map[int64]time.Time{
+		1: start.Add(time.Hour + first),
+		2: start.Add(2*time.Hour + second),
+		3: start.Add(3*time.Hour + time.Duration(own.Int63n(int64(max)))),
+	} {
+		if next, _ := cron.GetNextRun(id); !next.Equal(expected) {
+			t.Errorf("entry %d: (expected) %v != %v (actual)", id, expected, next)
+		}
+	}
+}
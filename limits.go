@@ -0,0 +1,93 @@
+package cron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limits restricts the specs ParseWithLimits accepts, for schedules from
+// untrusted sources. The zero value allows every spec Parse does.
+type Limits struct {
+	// The shortest time allowed between two activations, e.g. a minute to
+	// reject "@every 10s" and specs activated every second. 0 for no limit.
+	MinInterval time.Duration
+
+	// Descriptors not allowed, e.g. "@reboot" or "@every". Sun descriptors are
+	// named without their twilight and offset, e.g. "@dusk" for "@dusk:-4+1h".
+	DisallowedMacros []string
+
+	// The most activations allowed in a day. 0 for no limit.
+	MaxPerDay int
+}
+
+// ParseWithLimits is like Parse, but also returns an error wrapping ErrLimit
+// if the spec is valid but its schedule exceeds the limits.
+//
+// The interval and the activations per day are measured over the first day the
+// schedule is activated, from midnight until its first activation of the next
+// day.
+func ParseWithLimits(spec string, limits Limits) (Schedule, error) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLimits(spec, schedule, limits, time.Now()); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// checkLimits returns an error if the parsed spec exceeds the limits, measuring
+// them over the first day it is activated after now.
+func checkLimits(spec string, schedule Schedule, limits Limits, now time.Time) error {
+	if macro := specMacro(spec); macro != "" {
+		for _, disallowed := range limits.DisallowedMacros {
+			if macro == disallowed {
+				return fmt.Errorf("%w: %s is not allowed: %s", ErrLimit, macro, spec)
+			}
+		}
+	}
+	// @reboot runs once, but until started, it is due at the instant after any
+	// time, so there is nothing to measure.
+	if limits.MinInterval <= 0 && limits.MaxPerDay <= 0 || specMacro(spec) == "@reboot" {
+		return nil
+	}
+
+	first := schedule.Next(now)
+	if first.IsZero() {
+		return nil
+	}
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, first.Location())
+	end := day.AddDate(0, 0, 1)
+	count := 0
+	for t := schedule.Next(day.Add(-time.Nanosecond)); !t.IsZero() && t.Before(end); {
+		count++
+		if limits.MaxPerDay > 0 && count > limits.MaxPerDay {
+			return fmt.Errorf("%w: activated more than %d times a day: %s", ErrLimit, limits.MaxPerDay, spec)
+		}
+		next := schedule.Next(t)
+		if limits.MinInterval > 0 && !next.IsZero() && next.Sub(t) < limits.MinInterval {
+			return fmt.Errorf("%w: interval of %s is below %s: %s", ErrLimit, next.Sub(t), limits.MinInterval, spec)
+		}
+		t = next
+	}
+	return nil
+}
+
+// specMacro returns the name of the descriptor the spec starts with, after any
+// time zone, e.g. "@every" for "@every 5m", or "" if it doesn't.
+func specMacro(spec string) string {
+	fields := strings.Fields(spec)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "CRON_TZ=") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || fields[0][0] != '@' {
+		return ""
+	}
+	macro := fields[0]
+	if i := strings.IndexAny(macro[1:], ":+-"); i >= 0 {
+		macro = macro[:i+1]
+	}
+	return macro
+}
@@ -0,0 +1,57 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseWithLimits(t *testing.T) {
+	limits := Limits{
+		MinInterval:      time.Minute,
+		DisallowedMacros: []string{"@reboot", "@dusk"},
+		MaxPerDay:        24,
+	}
+	entries := []struct {
+		spec    string
+		allowed bool
+	}{
+		{"0 0 * * * *", true},
+		{"0 30 9 * * MON-FRI", true},
+		{"@every 1h", true},
+		{"@daily", true},
+		{"@sunset", true},
+		{"CRON_TZ=UTC 0 0 * * * *", true},
+		{"0 0 0 1 1 ?", true},
+		{"0 59 23,0 * * *", true},
+
+		{"* * * * * *", false},
+		{"*/30 * * * * *", false},
+		{"@every 30s", false},
+		{"@every 250ms", false},
+		{"0 * * * * *", false},
+		{"0 */30 * * * *", false},
+		{"0,30 59 23 * * *", false},
+		{"@reboot", false},
+		{"@dusk:nautical+1h", false},
+		{"CRON_TZ=UTC @reboot", false},
+	}
+	for _, c := range entries {
+		schedule, err := ParseWithLimits(c.spec, limits)
+		switch {
+		case c.allowed && err != nil:
+			t.Errorf("%s: unexpected error: %v", c.spec, err)
+		case !c.allowed && !errors.Is(err, ErrLimit):
+			t.Errorf("%s: expected an error wrapping ErrLimit, got %v", c.spec, err)
+		case !c.allowed && schedule != nil:
+			t.Errorf("%s: expected no schedule", c.spec)
+		}
+	}
+
+	if _, err := ParseWithLimits("60 * * * * *", limits); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("expected an invalid spec to fail as in Parse, got %v", err)
+	}
+	if _, err := ParseWithLimits("* * * * * *", Limits{}); err != nil {
+		t.Errorf("expected the zero Limits to allow anything, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package cron
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Option configures a Cron.
+type Option func(*Cron)
+
+// WithClock uses the given Clock instead of the system clock.
+func WithClock(clock Clock) Option {
+	return func(c *Cron) {
+		c.clock = clock
+	}
+}
+
+// WithCancelOnRemove cancels the context handed to the runs of a ContextJob in
+// progress when its entry is removed, rather than letting them finish.
+func WithCancelOnRemove() Option {
+	return func(c *Cron) {
+		c.cancelOnRemove = true
+	}
+}
+
+// WithInlineExecution runs the jobs one after the other on the scheduler's
+// goroutine rather than each in its own, in the order they are due, which
+// saves the overhead of starting a goroutine for very short jobs. A slow job
+// then delays every other job, and the scheduler itself, for as long as it
+// runs, so it is only suited to jobs that return right away. Inline jobs must
+// not call the Cron's methods, which wait for the scheduler and so would never
+// return; Reschedule works as usual.
+func WithInlineExecution() Option {
+	return func(c *Cron) {
+		c.inline = true
+	}
+}
+
+// WithTestMode makes the Cron run its entries only when Tick is called, rather
+// than as time passes, and one after the other on the scheduler's goroutine, so
+// that tests can drive it step by step. It is a testing aid, not meant for
+// production use.
+func WithTestMode() Option {
+	return func(c *Cron) {
+		c.testMode = true
+	}
+}
+
+// WithLogger logs the problems of the scheduler itself, such as a Schedule
+// whose Next goes back in time, to logger rather than to standard error.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Cron) {
+		c.logger = logger
+	}
+}
+
+// WithRunLogger makes the Cron log the start and end of each run of a job to
+// logger, with the id of the run, see RunIDFromContext, and of the entry.
+func WithRunLogger(logger *log.Logger) Option {
+	return func(c *Cron) {
+		c.runLogger = logger
+	}
+}
+
+// WithDryRun makes the Cron log the runs that are due to logger, with the id of
+// the entry and the time it was due, instead of running the jobs. The entries'
+// Next and Prev still advance as if they ran, so the sequence of runs can be
+// checked before trusting the jobs to the scheduler.
+func WithDryRun(logger *log.Logger) Option {
+	return func(c *Cron) {
+		c.dryRun = logger
+	}
+}
+
+// WithOverlapPolicy sets what the Cron does when an entry is due while its job
+// is still running from an earlier run: start another run, the default, skip
+// it, or queue it. It applies to every entry, without wrapping the jobs in
+// SkipIfStillRunning or DelayIfStillRunning.
+func WithOverlapPolicy(policy OverlapPolicy) Option {
+	return func(c *Cron) {
+		c.overlap = policy
+	}
+}
+
+// WithChain decorates every job added to the Cron with the given wrappers, the
+// first of them being the outermost one.
+func WithChain(wrappers ...JobWrapper) Option {
+	return func(c *Cron) {
+		c.chain = NewChain(wrappers...)
+	}
+}
+
+// WithoutSeconds makes the Cron parse specs as standard 5-field crontab specs,
+// starting with the minute, instead of starting with the second. Jobs then run
+// at second 0 of each matching minute.
+func WithoutSeconds() Option {
+	return func(c *Cron) {
+		c.parse = ParseStandard
+	}
+}
+
+// WithDayAnd makes the jobs added by spec run only on days matching both the
+// day of month and the day of week fields, rather than either when both are
+// restricted as in standard cron. "0 0 0 13 * FRI" then runs on Friday the
+// 13th only.
+func WithDayAnd() Option {
+	return func(c *Cron) {
+		c.dayAnd = true
+	}
+}
+
+// WithAutoSeconds makes the Cron take specs of both 5 and 6 fields, see
+// ParseAuto: 5 fields are a standard crontab spec starting with the minute, 6
+// fields start with the second. A seconds spec written with 5 fields, leaving
+// out the optional day of week, is then read as starting with the minute.
+func WithAutoSeconds() Option {
+	return func(c *Cron) {
+		c.parse = ParseAuto
+	}
+}
+
+// WithAddBuffer lets up to n entries added while running wait for the run loop,
+// so that bursts of adds don't block on it. The run loop takes the waiting
+// entries before acting on any later call, e.g. RemoveJob, PauseFunc or
+// Entries, so those see the entries as added. Entries still waiting when the
+// Cron stops are only added once it is started again.
+func WithAddBuffer(n int) Option {
+	return func(c *Cron) {
+		c.add = make(chan addition, n)
+	}
+}
+
+// WithCatchUp runs a job once when the scheduler wakes up after one or more of
+// its activation times were missed, e.g. because the machine was asleep. It
+// fires at most once per missed window, not once per missed activation, so a
+// long suspension doesn't cause a stampede. Without it, missed runs are
+// skipped.
+//
+// It also runs a job on a sun schedule right away when it is added, or the Cron
+// started, after the day's event went by, rather than waiting for the next
+// day's.
+func WithCatchUp() Option {
+	return func(c *Cron) {
+		c.catchUp = true
+	}
+}
+
+// EntryOption configures an entry when adding a job.
+type EntryOption func(*Entry)
+
+// WithLabels sets the labels of the entry, by which it can be grouped with
+// others, e.g. to pause them together with PauseByLabel.
+func WithLabels(labels map[string]string) EntryOption {
+	return func(e *Entry) {
+		e.Labels = copyLabels(labels)
+	}
+}
+
+// WithRecoverHandler makes Recover hand the panics of the job to handler rather
+// than logging them. It has no effect unless the Cron's chain has Recover.
+func WithRecoverHandler(handler RecoverHandler) EntryOption {
+	return func(e *Entry) {
+		e.onPanic = handler
+	}
+}
+
+// WithMeta attaches meta to the entry as its Meta, for the caller's own use.
+func WithMeta(meta interface{}) EntryOption {
+	return func(e *Entry) {
+		e.Meta = meta
+	}
+}
+
+// WithGroup adds the entry to the group with the given id, see RemoveGroup.
+func WithGroup(groupID string) EntryOption {
+	return func(e *Entry) {
+		e.GroupID = groupID
+	}
+}
+
+// WithPriority sets the entry's Priority: of the entries due at the same time,
+// those with a higher one are started first.
+func WithPriority(priority int) EntryOption {
+	return func(e *Entry) {
+		e.Priority = priority
+	}
+}
+
+// WithDedup makes AddFunc and AddJob return the id of an existing identical
+// entry, along with ErrDuplicate, instead of adding a second one. Entries are
+// identical if their specs are the same but for case and spacing, and their
+// jobs are equal; jobs that can't be compared, like funcs, are considered
+// equal.
+func WithDedup() Option {
+	return func(c *Cron) {
+		c.dedup = true
+	}
+}
+
+// withSpec records the spec the job was added with on the entry.
+func withSpec(spec string) EntryOption {
+	return func(e *Entry) {
+		e.Spec = spec
+	}
+}
+
+// WithSyncFirstRun makes the first run of the job happen within the scheduler,
+// before any job due at the same time is started, and before anything else is
+// scheduled. Later runs are asynchronous as usual. As it blocks the scheduler
+// for as long as the job runs, it is only suited to quick setup jobs that
+// other jobs depend on. That run must not call the Cron's methods, which wait
+// for the scheduler and so would never return; Reschedule works as usual.
+func WithSyncFirstRun() EntryOption {
+	return func(e *Entry) {
+		e.syncRun = true
+	}
+}
+
+// WithRunNow runs the job once as soon as it is added, or as soon as the Cron
+// is started if it isn't running yet, in addition to its schedule. The run goes
+// through the Cron's chain of wrappers and hooks like any other.
+func WithRunNow() EntryOption {
+	return func(e *Entry) {
+		e.runNow = true
+	}
+}
+
+// WithMaxConcurrency limits the number of jobs running at once to n. Runs that
+// are due while n jobs are running wait, in the order they were due, for one of
+// them to finish.
+func WithMaxConcurrency(n int) Option {
+	return func(c *Cron) {
+		c.maxConcurrency = n
+	}
+}
+
+// WithFairOrder rotates the order in which jobs that are due at the same time
+// are started, so that with WithMaxConcurrency the same jobs don't always wait
+// for the others.
+func WithFairOrder() Option {
+	return func(c *Cron) {
+		c.fair = true
+	}
+}
+
+// WithStartWarning logs a warning to logger if jobs are added but the Cron is
+// not started within grace of the first, which otherwise goes unnoticed as the
+// jobs just never run.
+func WithStartWarning(logger *log.Logger, grace time.Duration) Option {
+	return func(c *Cron) {
+		c.startLogger = logger
+		c.startGrace = grace
+	}
+}
+
+// WithRand makes all randomized behaviour of the Cron, like the offsets of the
+// schedules made with WithJitter, draw from r rather than a time-seeded
+// source, so that it is reproducible. The Cron takes over r, which should not
+// be used elsewhere.
+func WithRand(r *rand.Rand) Option {
+	return func(c *Cron) {
+		c.rand = newLockedRand(r)
+	}
+}
+
+// WithOverrunThreshold sets the share of the interval between two runs a job
+// may take before EventJobOverran is published for it, e.g. 0.8 to be warned
+// at 80% of the interval. The default is 1, to be warned once runs can't keep
+// up with the schedule.
+func WithOverrunThreshold(share float64) Option {
+	return func(c *Cron) {
+		c.overrunThreshold = share
+	}
+}
+
+// WithIdleHorizon sets how long the scheduler sleeps when it has no entry to
+// wait for, before taking another look. The default is ten years, as it wakes
+// up for new entries anyway. Either way, it takes another look at least once a
+// day, as it does when waiting for an entry.
+func WithIdleHorizon(d time.Duration) Option {
+	return func(c *Cron) {
+		c.idleHorizon = d
+	}
+}
+
+// WithWakeInterval makes the scheduler wake up at least every d to take
+// another look at its entries, rather than sleeping until the next one is due
+// however long that is. This bounds how late a job runs after the clock is set,
+// e.g. by NTP.
+func WithWakeInterval(d time.Duration) Option {
+	return func(c *Cron) {
+		c.wakeInterval = d
+	}
+}
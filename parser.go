@@ -1,6 +1,7 @@
 package cron
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -10,28 +11,139 @@ import (
 )
 
 // Parse returns a new crontab schedule representing the given spec.
-// It returns a descriptive error if the spec is not valid.
+// It returns a descriptive error wrapping ErrInvalidSpec if the spec is not
+// valid.
 //
 // It accepts
-//   - Full crontab specs, e.g. "* * * * * ?"
+//   - Full crontab specs starting with the second, e.g. "* * * * * ?"
 //   - Descriptors, e.g. "@midnight", "@every 1h30m"
-func Parse(spec string) (_ Schedule, err error) {
+//
+// Use ParseStandard for standard crontab specs, which start with the minute.
+func Parse(spec string) (Schedule, error) {
+	return parse(spec, true)
+}
+
+// ValidateSpec checks that the spec can be parsed, returning the error Parse
+// would return for it, without creating a schedule for a Cron.
+func ValidateSpec(spec string) error {
+	_, err := Parse(spec)
+	return err
+}
+
+// ParseWithWarnings is like Parse, but also returns warnings about a valid spec
+// that is likely not what was meant, e.g. a 5-field standard crontab spec,
+// which Parse reads as starting with the second.
+func ParseWithWarnings(spec string) (Schedule, []string, error) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schedule, specWarnings(spec, schedule), nil
+}
+
+// specWarnings returns the warnings about the parsed spec, see
+// ParseWithWarnings.
+func specWarnings(spec string, schedule Schedule) []string {
+	var warnings []string
+	if schedule.Next(time.Now()).IsZero() {
+		warnings = append(warnings, fmt.Sprintf("Schedule is never activated: %s", spec))
+	}
+	if s, ok := schedule.(*locationSchedule); ok {
+		schedule = s.Schedule
+		spec = strings.TrimSpace(strings.SplitN(spec, " ", 2)[1])
+	}
+	if s, ok := schedule.(PreciseDelaySchedule); ok {
+		warnings = append(warnings, fmt.Sprintf("Interval of %s is below a second: %s", s.Delay, spec))
+	}
+
+	fields := strings.Fields(spec)
+	switch {
+	case fields[0][0] == '@':
+	case len(fields) == 5:
+		warnings = append(warnings, fmt.Sprintf("5 fields are read as starting with the second, use ParseStandard for standard crontab specs: %s", spec))
+	case fields[0] == "*":
+		warnings = append(warnings, fmt.Sprintf("Seconds field is *, so the schedule is activated every second: %s", spec))
+	}
+	return warnings
+}
+
+// ParseStandard is like Parse, but expects standard 5-field crontab specs
+// starting with the minute, e.g. "30 9 * * MON-FRI"; the second is always 0.
+func ParseStandard(spec string) (Schedule, error) {
+	return parse(spec, false)
+}
+
+// ParseAuto takes both kinds of specs, telling them apart by the number of
+// fields: 6 fields start with the second, as for Parse, and 5 fields are a
+// standard crontab spec starting with the minute, as for ParseStandard.
+// Descriptors are accepted as usual.
+func ParseAuto(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "CRON_TZ=") {
+		fields = fields[1:]
+	}
+	return parse(spec, len(fields) != 5)
+}
+
+func parse(spec string, withSeconds bool) (_ Schedule, err error) {
 	// Convert panics into errors
 	defer func() {
 		if recovered := recover(); recovered != nil {
-			err = fmt.Errorf("%v", recovered)
+			switch recovered := recovered.(type) {
+			case *UnknownMacroError:
+				err = recovered
+				return
+			case *FieldError:
+				err = recovered
+				return
+			}
+			err = fmt.Errorf("%w: %v", ErrInvalidSpec, recovered)
 		}
 	}()
 
-	if spec[0] == '@' {
-		return parseDescriptor(spec), nil
+	// A leading CRON_TZ=<zone> evaluates the schedule in that time zone.
+	var loc *time.Location
+	const tzPrefix = "CRON_TZ="
+	if strings.HasPrefix(spec, tzPrefix) {
+		fields := strings.SplitN(spec, " ", 2)
+		name := fields[0][len(tzPrefix):]
+		if loc, err = time.LoadLocation(name); err != nil {
+			log.Panicf("Unknown time zone %q: %s", name, err)
+		}
+		if len(fields) == 1 {
+			log.Panicf("Missing schedule after time zone: %s", spec)
+		}
+		spec = strings.TrimSpace(fields[1])
 	}
 
-	// Split on whitespace.  We require 5 or 6 fields.
-	// (second) (minute) (hour) (day of month) (month) (day of week, optional)
+	schedule := parseSpec(spec, withSeconds)
+	if loc != nil {
+		return &locationSchedule{schedule, loc}, nil
+	}
+	return schedule, nil
+}
+
+// parseSpec returns the schedule for the spec, or panics if it is not valid.
+func parseSpec(spec string, withSeconds bool) Schedule {
+	// Split on whitespace, counting the fields before looking at any of them.
 	fields := strings.Fields(spec)
-	if len(fields) != 5 && len(fields) != 6 {
-		log.Panicf("Expected 5 or 6 fields, found %d: %s", len(fields), spec)
+	if len(fields) > 0 && fields[0][0] == '@' {
+		return parseDescriptor(strings.TrimSpace(spec))
+	}
+
+	if withSeconds {
+		// We require 5 or 6 fields.
+		// (second) (minute) (hour) (day of month) (month) (day of week, optional)
+		if len(fields) != 5 && len(fields) != 6 {
+			log.Panicf("Expected 5 or 6 fields, got %d: %s", len(fields), spec)
+		}
+	} else {
+		// We require exactly 5 fields, and the second is 0.
+		// (minute) (hour) (day of month) (month) (day of week)
+		if len(fields) != 5 {
+			log.Panicf("Expected 5 fields, got %d: %s", len(fields), spec)
+		}
+		fields = append([]string{"0"}, fields...)
 	}
 
 	// If a sixth field is not provided (DayOfWeek), then it is equivalent to star.
@@ -39,16 +151,26 @@ func Parse(spec string) (_ Schedule, err error) {
 		fields = append(fields, "*")
 	}
 
+	// "?" leaves a day field blank, so it only makes sense in those.
+	for _, i := range []int{0, 1, 2, 4} {
+		if strings.Contains(fields[i], "?") {
+			log.Panicf("? is only allowed in day of month and day of week: %s", spec)
+		}
+	}
+
+	days, nearestWeekday, fromMonthEnd := getDomField(fields[3])
 	schedule := &SpecSchedule{
-		Second: getField(fields[0], seconds),
-		Minute: getField(fields[1], minutes),
-		Hour:   getField(fields[2], hours),
-		Dom:    getField(fields[3], dom),
-		Month:  getField(fields[4], months),
-		Dow:    getField(fields[5], dow),
+		Second:         getField(fields[0], seconds),
+		Minute:         getField(fields[1], minutes),
+		Hour:           getField(fields[2], hours),
+		Dom:            days,
+		Month:          getField(fields[4], months),
+		Dow:            getDowField(fields[5]),
+		NearestWeekday: nearestWeekday,
+		FromMonthEnd:   fromMonthEnd,
 	}
 
-	return schedule, nil
+	return schedule
 }
 
 // getField returns an Int with the bits set representing all of the times that
@@ -57,12 +179,90 @@ func getField(field string, r bounds) uint64 {
 	// list = range {"," range}
 	var bits uint64
 	ranges := strings.FieldsFunc(field, func(r rune) bool { return r == ',' })
-	for _, expr := range ranges {
-		bits |= getRange(expr, r)
+	if len(ranges) == 1 {
+		return getElement(ranges[0], 0, r, getRange)
+	}
+	for i, expr := range ranges {
+		bits |= getElement(expr, i+1, r, getRange)
+	}
+	// A list restricts the field even if an element is a star, e.g. "*/15".
+	return bits &^ starBit
+}
+
+// getElement returns get(expr, r) for the index'th element of a list, counting
+// from 1, or for the whole field if index is 0. It panics with a *FieldError
+// naming the field and the element if the element is not valid.
+func getElement(expr string, index int, r bounds, get func(string, bounds) uint64) uint64 {
+	if r.name != "" {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				panic(&FieldError{Field: r.name, Element: index, Expr: expr, Reason: fmt.Sprint(recovered)})
+			}
+		}()
+	}
+	return get(expr, r)
+}
+
+// getDowField is getField for the day-of-week field, which also accepts 7 as
+// Sunday.
+func getDowField(field string) uint64 {
+	bits := getField(field, dowWithSeven)
+	if bits&(1<<7) > 0 {
+		bits = bits&^(1<<7) | 1<<0
 	}
 	return bits
 }
 
+// getDomField is getField for the day-of-month field, which also accepts days
+// with the W modifier, e.g. "15W", for the nearest weekday, and negative days,
+// e.g. "-1", counting from the end of the month. Those are returned separately.
+func getDomField(field string) (bits, nearestWeekday, fromMonthEnd uint64) {
+	var (
+		elements = strings.FieldsFunc(field, func(r rune) bool { return r == ',' })
+		ranges   int
+	)
+	for i, expr := range elements {
+		index := i + 1
+		if len(elements) == 1 {
+			index = 0
+		}
+		if strings.HasSuffix(expr, "W") || strings.HasSuffix(expr, "w") {
+			nearestWeekday |= getElement(expr, index, dom, getNearestWeekday)
+			continue
+		}
+		if strings.HasPrefix(expr, "-") {
+			fromMonthEnd |= getElement(expr, index, dom, getFromMonthEnd)
+			continue
+		}
+		bits |= getElement(expr, index, dom, getRange)
+		ranges++
+	}
+	if ranges > 1 {
+		bits &^= starBit
+	}
+	return bits, nearestWeekday, fromMonthEnd
+}
+
+// getFromMonthEnd returns the bit of the day in a negative day of month, e.g.
+// bit 1 for "-1", the last day of the month.
+func getFromMonthEnd(expr string, r bounds) uint64 {
+	day := mustParseInt(expr[1:])
+	if day < 1 || day > r.max {
+		log.Panicf("value -%d out of range -%d to -1: %s", day, r.max, expr)
+	}
+	return 1 << day
+}
+
+// getNearestWeekday returns the bit of the day in an expression with the W
+// modifier, e.g. "15W".
+func getNearestWeekday(expr string, r bounds) uint64 {
+	day := expr[:len(expr)-1]
+	if _, err := strconv.Atoi(day); err != nil {
+		log.Panicf("W is only allowed after a single day: %s", expr)
+	}
+	return getRange(day, r)
+}
+
 // getRange returns the bits indicated by the given expression:
 //   number | number "-" number [ "/" number ]
 func getRange(expr string, r bounds) uint64 {
@@ -80,6 +280,9 @@ func getRange(expr string, r bounds) uint64 {
 		end = r.max
 		extra_star = starBit
 	} else {
+		if len(lowAndHigh) == 2 && (lowAndHigh[0] == "" || lowAndHigh[1] == "") {
+			log.Panicf("Malformed range, expected start-end: %s", expr)
+		}
 		start = parseIntOrName(lowAndHigh[0], r.names)
 		switch len(lowAndHigh) {
 		case 1:
@@ -96,6 +299,9 @@ func getRange(expr string, r bounds) uint64 {
 		step = 1
 	case 2:
 		step = mustParseInt(rangeAndStep[1])
+		if step == 0 {
+			log.Panicf("Step of range should be a positive number: %s", expr)
+		}
 
 		// Special handling: "N/step" means "N-max/step".
 		if singleDigit {
@@ -105,14 +311,22 @@ func getRange(expr string, r bounds) uint64 {
 		log.Panicf("Too many slashes: %s", expr)
 	}
 
-	if start < r.min {
-		log.Panicf("Beginning of range (%d) below minimum (%d): %s", start, r.min, expr)
+	for _, value := range []uint{start, end} {
+		if value < r.min || value > r.max {
+			log.Panicf("value %d out of range %d-%d: %s", value, r.min, r.max, expr)
+		}
 	}
-	if end > r.max {
-		log.Panicf("End of range (%d) above maximum (%d): %s", end, r.max, expr)
+	if start > end {
+		if !r.wrap {
+			log.Panicf("Beginning of range (%d) beyond end of range (%d): %s", start, end, expr)
+		}
+		// Sunday is 7 as well as 0, but the days of the week wrap after 6.
+		if r.name == dowWithSeven.name {
+			r, start, end = dow, start%7, end%7
+		}
 	}
 	if start > end {
-		log.Panicf("Beginning of range (%d) beyond end of range (%d): %s", start, end, expr)
+		return getWrappedBits(start, end, step, r) | extra_star
 	}
 
 	return getBits(start, end, step) | extra_star
@@ -157,6 +371,19 @@ func getBits(min, max, step uint) uint64 {
 	return bits
 }
 
+// getWrappedBits sets the bits from start up to max and on from min up to end,
+// modulo the given step size, continuing the steps across the wrap.
+func getWrappedBits(start, end, step uint, r bounds) uint64 {
+	var (
+		bits uint64
+		span = r.max - r.min + 1
+	)
+	for i := uint(0); i <= end+span-start; i += step {
+		bits |= 1 << (r.min + (start-r.min+i)%span)
+	}
+	return bits
+}
+
 // all returns all bits within the given bounds.  (plus the star bit)
 func all(r bounds) uint64 {
 	return getBits(r.min, r.max, 1) | starBit
@@ -206,6 +433,9 @@ func parseDescriptor(spec string) Schedule {
 			Dow:    all(dow),
 		}
 
+	case "@reboot":
+		return &rebootSchedule{}
+
 	case "@hourly":
 		return &SpecSchedule{
 			Second: 1 << seconds.min,
@@ -215,19 +445,99 @@ func parseDescriptor(spec string) Schedule {
 			Month:  all(months),
 			Dow:    all(dow),
 		}
-	case "@sunset", "@sunrise", "@dusk", "@dawn":
-		return NewSunSchedule(spec)
+	}
+
+	if fields := strings.Fields(spec); len(fields) > 0 {
+		token, _, _ := splitSunOffset(fields[0][1:])
+		if state, _, _ := splitSunState(token); isSunState(state) {
+			schedule, err := NewSunSchedule(spec)
+			var fieldErr *FieldError
+			if errors.As(err, &fieldErr) {
+				panic(fieldErr)
+			}
+			if err != nil {
+				log.Panic(err)
+			}
+			return schedule
+		}
 	}
 
 	const every = "@every "
+	if spec == strings.TrimSpace(every) {
+		log.Panicf("Missing duration: %s", spec)
+	}
 	if strings.HasPrefix(spec, every) {
 		duration, err := time.ParseDuration(spec[len(every):])
 		if err != nil {
 			log.Panicf("Failed to parse duration %s: %s", spec, err)
 		}
+		if duration <= 0 {
+			log.Panicf("Duration must be positive: %s", spec)
+		}
+		if duration < time.Second {
+			return EveryPrecise(duration)
+		}
 		return Every(duration)
 	}
 
-	log.Panicf("Unrecognized descriptor: %s", spec)
-	return nil
+	panic(&UnknownMacroError{Macro: strings.Fields(spec + " ")[0]})
+}
+
+// macros lists the descriptors Parse supports, for UnknownMacroError.
+var macros = append([]string{
+	"@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight",
+	"@hourly", "@reboot", "@every <duration>",
+}, func() []string {
+	var states []string
+	for _, state := range sunStates {
+		states = append(states, "@"+state)
+	}
+	return states
+}()...)
+
+// UnknownMacroError is the error Parse returns for a spec starting with a
+// descriptor it doesn't support, e.g. "@fortnightly". It wraps ErrInvalidSpec.
+type UnknownMacroError struct {
+	// The descriptor, e.g. "@fortnightly".
+	Macro string
+}
+
+func (e *UnknownMacroError) Error() string {
+	return fmt.Sprintf("%v: Unknown descriptor %q, expected one of %s",
+		ErrInvalidSpec, e.Macro, strings.Join(macros, ", "))
+}
+
+// Unwrap returns ErrInvalidSpec.
+func (e *UnknownMacroError) Unwrap() error {
+	return ErrInvalidSpec
+}
+
+// FieldError is the error Parse returns for a spec with a field it can't parse,
+// e.g. "FRY" in "0 0 0 * * MON,WED,FRY". It wraps ErrInvalidSpec.
+type FieldError struct {
+	// The field, e.g. "day of week".
+	Field string
+
+	// The position of the element in the field's comma-separated list,
+	// counting from 1, e.g. 3 for "FRY" in "MON,WED,FRY". 0 if the field is
+	// not a list.
+	Element int
+
+	// The element, e.g. "FRY".
+	Expr string
+
+	// What is wrong with the element.
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	if e.Element == 0 {
+		return fmt.Sprintf("%v: %s: %s", ErrInvalidSpec, e.Field, e.Reason)
+	}
+	return fmt.Sprintf("%v: %s: element %d %q: %s", ErrInvalidSpec, e.Field, e.Element, e.Expr, e.Reason)
+}
+
+// Unwrap returns ErrInvalidSpec.
+func (e *FieldError) Unwrap() error {
+	return ErrInvalidSpec
 }
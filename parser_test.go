@@ -1,7 +1,9 @@
 package cron
 
 import (
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -29,7 +31,7 @@ func TestRange(t *testing.T) {
 	}
 
 	for _, c := range ranges {
-		actual := getRange(c.expr, bounds{c.min, c.max, nil})
+		actual := getRange(c.expr, bounds{c.min, c.max, nil, false, ""})
 		if actual != c.expected {
 			t.Errorf("%s => (expected) %d != %d (actual)", c.expr, c.expected, actual)
 		}
@@ -46,10 +48,12 @@ func TestField(t *testing.T) {
 		{"5,6", 1, 7, 1<<5 | 1<<6},
 		{"5,6,7", 1, 7, 1<<5 | 1<<6 | 1<<7},
 		{"1,5-7/2,3", 1, 7, 1<<1 | 1<<5 | 1<<7 | 1<<3},
+		{"1,5-10,*/15,30", 0, 59, 1<<0 | 1<<1 | 1<<5 | 1<<6 | 1<<7 | 1<<8 | 1<<9 | 1<<10 | 1<<15 | 1<<30 | 1<<45},
+		{"*,5", 0, 7, 1<<0 | 1<<1 | 1<<2 | 1<<3 | 1<<4 | 1<<5 | 1<<6 | 1<<7},
 	}
 
 	for _, c := range fields {
-		actual := getField(c.expr, bounds{c.min, c.max, nil})
+		actual := getField(c.expr, bounds{c.min, c.max, nil, false, ""})
 		if actual != c.expected {
 			t.Errorf("%s => (expected) %d != %d (actual)", c.expr, c.expected, actual)
 		}
@@ -101,8 +105,9 @@ func TestSpecSchedule(t *testing.T) {
 		expr     string
 		expected Schedule
 	}{
-		{"* 5 * * * *", &SpecSchedule{all(seconds), 1 << 5, all(hours), all(dom), all(months), all(dow)}},
+		{"* 5 * * * *", &SpecSchedule{all(seconds), 1 << 5, all(hours), all(dom), all(months), all(dow), 0, 0, 0, false}},
 		{"@every 5m", ConstantDelaySchedule{time.Duration(5) * time.Minute}},
+		{"@every 250ms", PreciseDelaySchedule{250 * time.Millisecond}},
 	}
 
 	for _, c := range entries {
@@ -115,3 +120,400 @@ func TestSpecSchedule(t *testing.T) {
 		}
 	}
 }
+
+func TestEveryDurations(t *testing.T) {
+	for _, c := range []struct {
+		spec     string
+		expected Schedule
+	}{
+		{"@every 1h30m10s", ConstantDelaySchedule{5410 * time.Second}},
+		{"@every 1s", ConstantDelaySchedule{time.Second}},
+		{"@every 1500ms", ConstantDelaySchedule{time.Second}},
+		{"@every 2.5s", ConstantDelaySchedule{2 * time.Second}},
+		{"@every 500ms", PreciseDelaySchedule{500 * time.Millisecond}},
+	} {
+		actual, err := Parse(c.spec)
+		if err != nil {
+			t.Errorf("%s: %v", c.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s => (expected) %v != %v (actual)", c.spec, c.expected, actual)
+		}
+	}
+
+	for _, spec := range []string{"@every 0s", "@every -1s", "@every 1", "@every"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestParseStandard(t *testing.T) {
+	entries := []struct {
+		expr     string
+		expected Schedule
+	}{
+		{"* * * * *", &SpecSchedule{1 << seconds.min, all(minutes), all(hours), all(dom), all(months), all(dow), 0, 0, 0, false}},
+		{"5 * * * *", &SpecSchedule{1 << seconds.min, 1 << 5, all(hours), all(dom), all(months), all(dow), 0, 0, 0, false}},
+		{"@every 5m", ConstantDelaySchedule{time.Duration(5) * time.Minute}},
+	}
+
+	for _, c := range entries {
+		actual, err := ParseStandard(c.expr)
+		if err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s => (expected) %b != %b (actual)", c.expr, c.expected, actual)
+		}
+	}
+
+	if _, err := ParseStandard("* * * * * *"); err == nil {
+		t.Error("expected an error for 6 fields")
+	}
+}
+
+func TestParseWithSeconds(t *testing.T) {
+	actual, err := Parse("* * * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &SpecSchedule{all(seconds), all(minutes), all(hours), all(dom), all(months), all(dow), 0, 0, 0, false}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("(expected) %+v != %+v (actual)", expected, actual)
+	}
+}
+
+func TestSteps(t *testing.T) {
+	steps := []struct {
+		expr     string
+		r        bounds
+		expected uint64
+	}{
+		{"*/15", minutes, 1<<0 | 1<<15 | 1<<30 | 1<<45 | starBit},
+		{"0-30/10", minutes, 1<<0 | 1<<10 | 1<<20 | 1<<30},
+		{"10-50/10", minutes, 1<<10 | 1<<20 | 1<<30 | 1<<40 | 1<<50},
+		{"*/5", dom, 1<<1 | 1<<6 | 1<<11 | 1<<16 | 1<<21 | 1<<26 | 1<<31 | starBit},
+	}
+
+	for _, c := range steps {
+		actual := getField(c.expr, c.r)
+		if actual != c.expected {
+			t.Errorf("%s => (expected) %b != %b (actual)", c.expr, c.expected, actual)
+		}
+	}
+
+	for _, spec := range []string{"0 */0 * * * *", "0 0-30/0 * * * *", "0 */-5 * * * *", "0 */x * * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestSundayAsSeven(t *testing.T) {
+	fields := []struct {
+		expr, equivalent string
+	}{
+		{"7", "0"},
+		{"sun", "0"},
+		{"5-7", "0,5,6"},
+		{"0-7", "*"},
+		{"*/2", "0,2,4,6"},
+	}
+
+	for _, c := range fields {
+		actual, expected := getDowField(c.expr), getDowField(c.equivalent)&^starBit
+		if actual&^starBit != expected {
+			t.Errorf("%s => (expected) %b != %b (actual)", c.expr, expected, actual)
+		}
+	}
+
+	seven, _ := Parse("0 0 0 * * 7")
+	zero, _ := Parse("0 0 0 * * 0")
+	if !reflect.DeepEqual(seven, zero) {
+		t.Errorf("(expected) %b != %b (actual)", zero, seven)
+	}
+}
+
+func TestWrapAroundRange(t *testing.T) {
+	ranges := []struct {
+		expr     string
+		r        bounds
+		expected uint64
+	}{
+		{"22-2", hours, 1<<22 | 1<<23 | 1<<0 | 1<<1 | 1<<2},
+		{"22-2/2", hours, 1<<22 | 1<<0 | 1<<2},
+		{"55-5/5", minutes, 1<<55 | 1<<0 | 1<<5},
+		{"58-1", seconds, 1<<58 | 1<<59 | 1<<0 | 1<<1},
+	}
+
+	for _, c := range ranges {
+		actual := getRange(c.expr, c.r)
+		if actual != c.expected {
+			t.Errorf("%s => (expected) %b != %b (actual)", c.expr, c.expected, actual)
+		}
+	}
+
+	for _, c := range []struct{ expr, equivalent string }{
+		{"5-1", "0,1,5,6"},
+		{"FRI-TUE/2", "0,2,5"},
+		{"SAT-MON/2", "1,6"},
+		{"7-2/2", "0,2"},
+		{"6-0/2", "6"},
+	} {
+		if actual, expected := getDowField(c.expr), getDowField(c.equivalent); actual != expected {
+			t.Errorf("%s => (expected) %b != %b (actual)", c.expr, expected, actual)
+		}
+	}
+
+	for _, spec := range []string{"0 0 0 20-10 * *", "0 0 0 * 11-2 *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: expected an error, day of month and month ranges don't wrap", spec)
+		}
+	}
+}
+
+func TestNamedRanges(t *testing.T) {
+	for _, c := range []struct{ expr, equivalent string }{
+		{"MON-FRI", "1-5"},
+		{"mon-fri", "1-5"},
+		{"FRI-MON", "5,6,0,1"},
+		{"SAT-SUN", "6,0"},
+		{"SUN-SAT", "0-6"},
+		{"MON-FRI/2", "1,3,5"},
+	} {
+		if actual, expected := getDowField(c.expr), getDowField(c.equivalent); actual != expected {
+			t.Errorf("%s => (expected) %b != %b (actual)", c.expr, expected, actual)
+		}
+	}
+	if actual, expected := getField("JAN-MAR", months), getField("1-3", months); actual != expected {
+		t.Errorf("JAN-MAR => (expected) %b != %b (actual)", expected, actual)
+	}
+
+	for _, c := range []struct{ spec, expected string }{
+		{"0 0 0 * * MON-", "day of week: Malformed range"},
+		{"0 0 0 * * -FRI", "day of week: Malformed range"},
+		{"0 0 0 * * MON-FOO", "day of week: Failed to parse int from FOO"},
+		{"0 0 0 * * MON-TUE-WED", "day of week: Too many hyphens"},
+		{"0 0 0 * MAR-JAN *", "month: Beginning of range (3) beyond end of range (1)"},
+	} {
+		_, err := Parse(c.spec)
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", c.spec, c.expected, err)
+		}
+	}
+}
+
+func TestQuestionMark(t *testing.T) {
+	for _, c := range []struct{ expr, equivalent string }{
+		{"0 0 0 ? * MON", "0 0 0 * * MON"},
+		{"0 0 0 1 * ?", "0 0 0 1 * *"},
+	} {
+		actual, err := Parse(c.expr)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		expected, _ := Parse(c.equivalent)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s => (expected) %b != %b (actual)", c.expr, expected, actual)
+		}
+	}
+
+	for _, spec := range []string{"? 0 0 * * *", "0 ? 0 * * *", "0 0 ? * * *", "0 0 0 * ? *", "0 0 1,? * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	errors := []struct {
+		spec, expected string
+	}{
+		{"0 75 * * * *", "minute: value 75 out of range 0-59"},
+		{"0 0 0 * 13 *", "month: value 13 out of range 1-12"},
+		{"0 10-75 * * * *", `minute: value 75 out of range 0-59: 10-75`},
+		{"0 0 24 * * *", "hour: value 24 out of range 0-23"},
+		{"0 0 0 0 * *", "day of month: value 0 out of range 1-31"},
+		{"0 0 0 * * x", "day of week: "},
+		{"60 * * * * *", "second: "},
+		{"0 1,5-10,*/x,30 * * * *", `minute: element 3 "*/x": Failed to parse int from x`},
+		{"0 1,5-75 * * * *", `minute: element 2 "5-75": value 75 out of range 0-59`},
+	}
+
+	for _, c := range errors {
+		_, err := Parse(c.spec)
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", c.spec, c.expected, err)
+		}
+	}
+}
+
+func TestFieldErrorElement(t *testing.T) {
+	for _, c := range []struct {
+		spec, field string
+		element     int
+		expr        string
+		expected    string
+	}{
+		{"0 0 0 * * MON,WED,FRY", "day of week", 3, "FRY", `Invalid spec: day of week: element 3 "FRY": Failed to parse int from FRY`},
+		{"0 0 0 1,15,40 * *", "day of month", 3, "40", `Invalid spec: day of month: element 3 "40": value 40 out of range 1-31: 40`},
+		{"0 0 0 1W,1-5W * *", "day of month", 2, "1-5W", `Invalid spec: day of month: element 2 "1-5W": W is only allowed after a single day: 1-5W`},
+		{"0 0 25 * * *", "hour", 0, "25", "Invalid spec: hour: value 25 out of range 0-23: 25"},
+		{"@sunset 1,2,x", "day of month", 3, "x", `Invalid spec: day of month: element 3 "x": Failed to parse int from x`},
+	} {
+		_, err := Parse(c.spec)
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Errorf("%s: expected a FieldError, got %v", c.spec, err)
+			continue
+		}
+		if fieldErr.Field != c.field || fieldErr.Element != c.element || fieldErr.Expr != c.expr {
+			t.Errorf("%s: expected element %d %q of the %s field, got %d %q of the %s field",
+				c.spec, c.element, c.expr, c.field, fieldErr.Element, fieldErr.Expr, fieldErr.Field)
+		}
+		if !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("%s: expected the error to wrap ErrInvalidSpec", c.spec)
+		}
+		if !strings.HasPrefix(err.Error(), c.expected) {
+			t.Errorf("%s: expected an error starting with %q, got %q", c.spec, c.expected, err)
+		}
+	}
+}
+
+func TestFieldCount(t *testing.T) {
+	for _, c := range []struct {
+		spec     string
+		parse    func(string) (Schedule, error)
+		expected string
+	}{
+		{"* * * *", Parse, "Expected 5 or 6 fields, got 4: * * * *"},
+		{"* * * * * * *", Parse, "Expected 5 or 6 fields, got 7: * * * * * * *"},
+		{"", Parse, "Expected 5 or 6 fields, got 0: "},
+		{"CRON_TZ=UTC  ", Parse, "Expected 5 or 6 fields, got 0: "},
+		{"* * * *", ParseStandard, "Expected 5 fields, got 4: * * * *"},
+		{"0 * * * * *", ParseStandard, "Expected 5 fields, got 6: 0 * * * * *"},
+	} {
+		_, err := c.parse(c.spec)
+		if err == nil || err.Error() != "Invalid spec: "+c.expected {
+			t.Errorf("%q: expected error %q, got %v", c.spec, c.expected, err)
+		}
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	valid := []string{
+		"0 30 9 * * MON-FRI",
+		"0 0/15 * * *",
+		"@every 1h30m",
+		"@daily",
+		"@sunset",
+		"@dusk:nautical * * 1-5",
+		"CRON_TZ=UTC 0 0 9 * * *",
+	}
+	for _, spec := range valid {
+		if err := ValidateSpec(spec); err != nil {
+			t.Errorf("%s: %v", spec, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"0 75 * * * *",
+		"@every never",
+		"@fortnightly",
+		"@sunset 32 * *",
+		"@sunrise * 13 *",
+	}
+	for _, spec := range invalid {
+		if err := ValidateSpec(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestParseWithWarnings(t *testing.T) {
+	tests := []struct {
+		spec     string
+		warnings int
+	}{
+		{"0 30 9 * * MON-FRI", 0},
+		{"@every 1m", 0},
+		{"@every 250ms", 1},
+		{"0 0 * * *", 1},
+		{"* 30 9 * * *", 1},
+		{"0 0 0 30 Feb ?", 1},
+		{"CRON_TZ=UTC * 30 9 * * *", 1},
+	}
+	for _, test := range tests {
+		schedule, warnings, err := ParseWithWarnings(test.spec)
+		if err != nil || schedule == nil {
+			t.Errorf("%s: unexpected error %v", test.spec, err)
+			continue
+		}
+		if len(warnings) != test.warnings {
+			t.Errorf("%s: expected %d warnings, got %q", test.spec, test.warnings, warnings)
+		}
+	}
+
+	if _, _, err := ParseWithWarnings("* * *"); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("expected ErrInvalidSpec, got %v", err)
+	}
+}
+
+func TestParseAuto(t *testing.T) {
+	tests := []struct{ spec, same string }{
+		{"30 9 * * MON-FRI", "0 30 9 * * MON-FRI"},
+		{"15 30 9 * * MON-FRI", "15 30 9 * * MON-FRI"},
+		{"CRON_TZ=UTC 30 9 * * *", "CRON_TZ=UTC 0 30 9 * * *"},
+		{"CRON_TZ=UTC 15 30 9 * * *", "CRON_TZ=UTC 15 30 9 * * *"},
+		{"@hourly", "@hourly"},
+	}
+	for _, test := range tests {
+		actual, err := ParseAuto(test.spec)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		expected, _ := Parse(test.same)
+		if !SchedulesEqual(actual, expected) {
+			t.Errorf("%s: (expected) %+v != %+v (actual)", test.spec, expected, actual)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "* * * * * * *"} {
+		if _, err := ParseAuto(spec); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("%s: expected ErrInvalidSpec, got %v", spec, err)
+		}
+	}
+}
+
+func TestParseUnknownMacro(t *testing.T) {
+	for _, c := range []struct{ spec, macro string }{
+		{"@fortnightly", "@fortnightly"},
+		{"@", "@"},
+		{"@sunsetx * * MON", "@sunsetx"},
+		{"CRON_TZ=UTC @fortnightly", "@fortnightly"},
+	} {
+		_, err := Parse(c.spec)
+		var unknown *UnknownMacroError
+		if !errors.As(err, &unknown) || unknown.Macro != c.macro {
+			t.Errorf("%s: expected an UnknownMacroError for %s, got %v", c.spec, c.macro, err)
+			continue
+		}
+		if !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("%s: expected the error to wrap ErrInvalidSpec", c.spec)
+		}
+		for _, macro := range []string{c.macro, "@daily", "@every <duration>", "@sunset"} {
+			if !strings.Contains(err.Error(), macro) {
+				t.Errorf("%s: expected the error to mention %s: %v", c.spec, macro, err)
+			}
+		}
+	}
+
+	if _, err := Parse("@every"); err == nil || !strings.Contains(err.Error(), "Missing duration") {
+		t.Errorf("expected a missing duration error, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package cron
+
+import (
+	"sync"
+	"time"
+)
+
+// rebootSchedule activates once, when the Cron it is added to starts, or right
+// away if it is added to a running Cron, and never again. The Cron runs the job
+// when it starts the schedule, see startReboot, rather than at a time Next
+// returns, so that calling Next doesn't use up the activation.
+type rebootSchedule struct {
+	mu      sync.Mutex
+	started bool
+}
+
+// Next returns the zero time once a Cron started the schedule. Until then, the
+// schedule is due as soon as one does, which is taken to be the instant after
+// t.
+func (s *rebootSchedule) Next(t time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return time.Time{}
+	}
+	return t.Add(time.Nanosecond)
+}
+
+// String returns "@reboot".
+func (s *rebootSchedule) String() string { return "@reboot" }
+
+// start reports whether the schedule wasn't started before, starting it.
+func (s *rebootSchedule) start() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	started := s.started
+	s.started = true
+	return !started
+}
+
+// startReboot starts the @reboot schedules among the schedule and those it
+// wraps, reporting whether that is to run the job. It doesn't for those behind
+// a gate, see GatedBy, which can't be open yet when the Cron starts.
+func startReboot(schedule Schedule) bool {
+	run := false
+	if s, ok := schedule.(*rebootSchedule); ok {
+		run = s.start()
+	}
+	_, gated := schedule.(*gatedSchedule)
+	for _, inner := range innerSchedules(schedule) {
+		if startReboot(inner) && !gated {
+			run = true
+		}
+	}
+	return run
+}
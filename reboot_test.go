@@ -0,0 +1,120 @@
+package cron
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRebootNext(t *testing.T) {
+	sched, err := Parse("@reboot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := getTime("Mon Jul 9 14:00 2012")
+	expected := now.Add(time.Nanosecond)
+	for i := 0; i < 2; i++ {
+		if actual := sched.Next(now); actual != expected {
+			t.Errorf("(expected) %v != %v (actual)", expected, actual)
+		}
+	}
+
+	if !startReboot(sched) {
+		t.Error("expected starting the schedule to run the job")
+	}
+	if startReboot(sched) {
+		t.Error("expected starting the schedule again not to run the job")
+	}
+	if actual := sched.Next(now); !actual.IsZero() {
+		t.Errorf("expected zero time once started, got %v", actual)
+	}
+}
+
+// Test that looking at an @reboot entry before the Cron starts doesn't use up
+// its run.
+func TestRebootProbedBeforeStart(t *testing.T) {
+	probes := []struct {
+		name  string
+		probe func(*Cron, int64)
+	}{
+		{"Health", func(c *Cron, id int64) {
+			if h := c.Health(); h.Unsatisfiable != 0 {
+				t.Errorf("Health: expected no unsatisfiable entries, got %d", h.Unsatisfiable)
+			}
+		}},
+		{"IsSatisfiable", func(c *Cron, id int64) {
+			if ok, err := c.IsSatisfiable(id); !ok || err != nil {
+				t.Errorf("IsSatisfiable: expected true, got %v, %v", ok, err)
+			}
+		}},
+	}
+	for _, p := range probes {
+		t.Run(p.name, func(t *testing.T) {
+			ran := make(chan struct{}, 10)
+			cron := New()
+			id, _ := cron.AddFunc("@reboot", func() { ran <- struct{}{} })
+			p.probe(cron, id)
+			p.probe(cron, id)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cron.Start(ctx)
+
+			select {
+			case <-ran:
+			case <-time.After(ONE_SECOND):
+				t.Fatal("@reboot job did not run at start")
+			}
+		})
+	}
+
+	t.Run("ParseWithWarnings", func(t *testing.T) {
+		sched, warnings, err := ParseWithWarnings("@reboot")
+		if err != nil || len(warnings) != 0 {
+			t.Fatalf("expected no error or warnings, got %v, %v", err, warnings)
+		}
+		ran := make(chan struct{}, 10)
+		cron := New()
+		cron.AddSchedule(sched, FuncJob(func() { ran <- struct{}{} }))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cron.Start(ctx)
+
+		select {
+		case <-ran:
+		case <-time.After(ONE_SECOND):
+			t.Fatal("@reboot job did not run at start")
+		}
+	})
+}
+
+// Test that an @reboot job runs once when the scheduler starts.
+func TestRebootRunsOnce(t *testing.T) {
+	clock := NewFakeClock(getTime("Mon Jul 9 14:00 2012").Local())
+	ran := make(chan struct{}, 10)
+
+	cron := New(WithClock(clock))
+	id, _ := cron.AddFunc("@reboot", func() { ran <- struct{}{} })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	select {
+	case <-ran:
+	case <-time.After(ONE_SECOND):
+		t.Fatal("@reboot job did not run at start")
+	}
+
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(24 * time.Hour)
+	}
+	select {
+	case <-ran:
+		t.Error("@reboot job ran more than once")
+	default:
+	}
+	if entry, _ := cron.EntryByID(id); !entry.Next.IsZero() {
+		t.Errorf("expected zero Next, got %v", entry.Next)
+	}
+}
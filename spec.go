@@ -1,25 +1,92 @@
 package cron
 
-import "time"
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
 
 // SpecSchedule specifies a duty cycle (to the second granularity), based on a
 // traditional crontab specification. It is computed initially and stored as bit sets.
 type SpecSchedule struct {
 	Second, Minute, Hour, Dom, Month, Dow uint64
+
+	// NearestWeekday holds the days of the month given with the W modifier,
+	// e.g. "15W": the schedule is activated on the weekday nearest each of
+	// them, without crossing into an adjacent month.
+	NearestWeekday uint64
+
+	// FromMonthEnd holds the days of the month given as negative numbers,
+	// counting back from the end of the month: bit 1 for "-1", the last day,
+	// bit 2 for "-2", the one before it, and so on.
+	FromMonthEnd uint64
+
+	// SearchYears bounds how many years ahead Next looks for an activation
+	// time, or DefaultSearchYears if it is 0.
+	SearchYears int
+
+	// DayAnd makes a day match only if both the day of month and the day of
+	// week fields match it, even when both are restricted. See dayMatches.
+	DayAnd bool
+}
+
+// DefaultSearchYears is how many years ahead SpecSchedule.Next looks by
+// default. It is enough to find the sparsest satisfiable schedule, February 29
+// across a century, which is eight years apart e.g. from 2096 to 2104.
+const DefaultSearchYears = 8
+
+// NewSpecSchedule returns the schedule activated at the given values of each
+// field, as a spec listing them would be, e.g. []int{0, 30} for the minutes
+// "0,30". An empty field stands for every value, like "*". Days of week go from
+// 0 to 6 starting on Sunday, which may also be 7. It returns an error wrapping
+// ErrInvalidSpec for values out of range.
+func NewSpecSchedule(second, minute, hour, dayOfMonth, month, dayOfWeek []int) (*SpecSchedule, error) {
+	s := &SpecSchedule{}
+	for _, f := range []struct {
+		bits   *uint64
+		values []int
+		r      bounds
+	}{
+		{&s.Second, second, seconds},
+		{&s.Minute, minute, minutes},
+		{&s.Hour, hour, hours},
+		{&s.Dom, dayOfMonth, dom},
+		{&s.Month, month, months},
+		{&s.Dow, dayOfWeek, dowWithSeven},
+	} {
+		if len(f.values) == 0 {
+			*f.bits = all(f.r)
+			continue
+		}
+		for _, v := range f.values {
+			if v < int(f.r.min) || v > int(f.r.max) {
+				return nil, fmt.Errorf("%w: %s %d out of range %d-%d", ErrInvalidSpec, f.r.name, v, f.r.min, f.r.max)
+			}
+			*f.bits |= 1 << uint(v)
+		}
+	}
+	if s.Dow&(1<<7) > 0 {
+		s.Dow = s.Dow&^(1<<7) | 1<<0
+	}
+	return s, nil
 }
 
-// bounds provides a range of acceptable values (plus a map of name to value).
+// bounds provides a range of acceptable values (plus a map of name to value),
+// and whether ranges in the field may wrap around from max to min. The field
+// name is used in error messages.
 type bounds struct {
 	min, max uint
 	names    map[string]uint
+	wrap     bool
+	name     string
 }
 
 // The bounds for each field.
 var (
-	seconds = bounds{0, 59, nil}
-	minutes = bounds{0, 59, nil}
-	hours   = bounds{0, 23, nil}
-	dom     = bounds{1, 31, nil}
+	seconds = bounds{0, 59, nil, true, "second"}
+	minutes = bounds{0, 59, nil, true, "minute"}
+	hours   = bounds{0, 23, nil, true, "hour"}
+	dom     = bounds{1, 31, nil, false, "day of month"}
 	months  = bounds{1, 12, map[string]uint{
 		"jan": 1,
 		"feb": 2,
@@ -33,7 +100,7 @@ var (
 		"oct": 10,
 		"nov": 11,
 		"dec": 12,
-	}}
+	}, false, "month"}
 	dow = bounds{0, 6, map[string]uint{
 		"sun": 0,
 		"mon": 1,
@@ -42,7 +109,11 @@ var (
 		"thu": 4,
 		"fri": 5,
 		"sat": 6,
-	}}
+	}, true, "day of week"}
+
+	// dowWithSeven is the day-of-week range accepted by the parser, where 7 is
+	// Sunday as well as 0.
+	dowWithSeven = bounds{0, 7, dow.names, true, dow.name}
 )
 
 const (
@@ -51,7 +122,8 @@ const (
 )
 
 // Next returns the next time this schedule is activated, greater than the given
-// time.  If no time can be found to satisfy the schedule, return the zero time.
+// time.  If no time within SearchYears satisfies the schedule, it returns the
+// zero time, which a Cron takes to mean that the entry never runs again.
 func (s *SpecSchedule) Next(t time.Time) time.Time {
 	// General approach:
 	// For Month, Day, Hour, Minute, Second:
@@ -67,8 +139,12 @@ func (s *SpecSchedule) Next(t time.Time) time.Time {
 	// This flag indicates whether a field has been incremented.
 	added := false
 
-	// If no time is found within five years, return zero.
-	yearLimit := t.Year() + 5
+	// If no time is found within the search bound, return zero.
+	searchYears := s.SearchYears
+	if searchYears == 0 {
+		searchYears = DefaultSearchYears
+	}
+	yearLimit := t.Year() + searchYears
 
 WRAP:
 	if t.Year() > yearLimit {
@@ -146,14 +222,154 @@ WRAP:
 
 // dayMatches returns true if the schedule's day-of-week and day-of-month
 // restrictions are satisfied by the given time.
+//
+// As in Vixie cron, if both fields are restricted, i.e. neither is "*" or "?",
+// a day matches if either of them does: "0 0 0 13 * FRI" runs on every 13th and
+// every Friday. With DayAnd set both must match, so it runs on Friday the 13th.
 func dayMatches(s *SpecSchedule, t time.Time) bool {
 	var (
-		domMatch bool = 1<<uint(t.Day())&s.Dom > 0
+		domMatch bool = 1<<uint(t.Day())&s.Dom > 0 || isNearestWeekday(s.NearestWeekday, t) ||
+			isFromMonthEnd(s.FromMonthEnd, t)
 		dowMatch bool = 1<<uint(t.Weekday())&s.Dow > 0
 	)
 
-	if s.Dom&starBit > 0 || s.Dow&starBit > 0 {
+	if s.DayAnd || s.Dom&starBit > 0 || s.Dow&starBit > 0 {
 		return domMatch && dowMatch
 	}
 	return domMatch || dowMatch
 }
+
+// isNearestWeekday reports whether t is on the weekday nearest one of the
+// given days of its month.
+func isNearestWeekday(days uint64, t time.Time) bool {
+	if days == 0 {
+		return false
+	}
+	for day := dom.min; day <= dom.max; day++ {
+		if 1<<day&days > 0 && nearestWeekday(t.Year(), t.Month(), int(day), t.Location()) == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// isFromMonthEnd reports whether t is on one of the given days counted back
+// from the end of its month, see SpecSchedule.FromMonthEnd.
+func isFromMonthEnd(days uint64, t time.Time) bool {
+	if days == 0 {
+		return false
+	}
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	return 1<<uint(last-t.Day()+1)&days > 0
+}
+
+// nearestWeekday returns the weekday nearest the given day of the month,
+// staying within the month: a Saturday moves back to Friday unless it is the
+// 1st, and a Sunday moves on to Monday unless it is the last day. Days beyond
+// the end of the month are taken as its last day.
+func nearestWeekday(year int, month time.Month, day int, loc *time.Location) int {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	if day > last {
+		day = last
+	}
+	switch time.Date(year, month, day, 0, 0, 0, 0, loc).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+// locationSchedule evaluates a schedule in a fixed time zone, regardless of the
+// location of the times it is given.
+type locationSchedule struct {
+	Schedule
+	loc *time.Location
+}
+
+// withDayAnd returns the schedule with DayAnd set on the spec schedule it is
+// made of, if any.
+func withDayAnd(schedule Schedule) Schedule {
+	switch s := schedule.(type) {
+	case *SpecSchedule:
+		and := *s
+		and.DayAnd = true
+		return &and
+	case *locationSchedule:
+		return &locationSchedule{withDayAnd(s.Schedule), s.loc}
+	case *SunSchedule:
+		and := *s
+		and.days = withDayAnd(s.days).(*SpecSchedule)
+		return &and
+	}
+	return schedule
+}
+
+// Next returns the next activation time of the schedule in its time zone.
+func (s *locationSchedule) Next(t time.Time) time.Time {
+	return s.Schedule.Next(t.In(s.loc))
+}
+
+// nextProbe is like Next, but also reports whether the time is a probe, see
+// GatedBy.
+func (s *locationSchedule) nextProbe(t time.Time) (time.Time, bool) {
+	return nextProbe(s.Schedule, t.In(s.loc))
+}
+
+// SchedulesEqual reports whether a and b are the same schedule, without going
+// through specs: spec schedules activated at the same times, delay schedules
+// with the same delay, sun schedules for the same event, days, coordinates and
+// fallback, and equal schedules in the same time zone or with the same jitter.
+// Other schedules are compared by String if they have one, and as values
+// otherwise.
+func SchedulesEqual(a, b Schedule) bool {
+	switch a := a.(type) {
+	case *SpecSchedule:
+		b, ok := b.(*SpecSchedule)
+		return ok && specsEqual(a, b)
+	case ConstantDelaySchedule, PreciseDelaySchedule:
+		return a == b
+	case *SunSchedule:
+		b, ok := b.(*SunSchedule)
+		return ok && a.state == b.state && a.twilight == b.twilight && a.offset == b.offset &&
+			a.lat == b.lat && a.lng == b.lng && a.fallback == b.fallback && specsEqual(a.days, b.days)
+	case *locationSchedule:
+		b, ok := b.(*locationSchedule)
+		return ok && a.loc.String() == b.loc.String() && SchedulesEqual(a.Schedule, b.Schedule)
+	case *jitterSchedule:
+		b, ok := b.(*jitterSchedule)
+		return ok && a.max == b.max && SchedulesEqual(a.inner, b.inner)
+	case *rebootSchedule:
+		_, ok := b.(*rebootSchedule)
+		return ok
+	}
+	if a, ok := a.(fmt.Stringer); ok {
+		if b, ok := b.(fmt.Stringer); ok {
+			return a.String() == b.String()
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// specsEqual reports whether a and b are activated at the same times. The star
+// bit only matters for the day fields, and a SearchYears of 0 means the
+// default.
+func specsEqual(a, b *SpecSchedule) bool {
+	x, y := *a, *b
+	for _, field := range []*uint64{&x.Second, &x.Minute, &x.Hour, &x.Month, &y.Second, &y.Minute, &y.Hour, &y.Month} {
+		*field &^= starBit
+	}
+	for _, years := range []*int{&x.SearchYears, &y.SearchYears} {
+		if *years == 0 {
+			*years = DefaultSearchYears
+		}
+	}
+	return x == y
+}
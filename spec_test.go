@@ -1,6 +1,7 @@
 package cron
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -147,6 +148,25 @@ func TestNext(t *testing.T) {
 		{"2012-11-04T00:00:00-0400", "0 0 3 * * ?", "2012-11-04T03:00:00-0500"},
 		{"2012-11-04T03:00:00-0500", "0 0 3 * * ?", "2012-11-05T03:00:00-0500"},
 
+		// Nearest weekday
+		{"Sat Sep 1 00:00 2012", "0 0 0 15W * ?", "Fri Sep 14 00:00 2012"},
+		{"Mon Jul 9 00:00 2012", "0 0 0 15W * ?", "Mon Jul 16 00:00 2012"},
+		{"Tue Jul 17 00:00 2012", "0 0 0 15W * ?", "Wed Aug 15 00:00 2012"},
+		{"Fri Aug 31 00:00 2012", "0 0 0 1W * ?", "Mon Sep 3 00:00 2012"},
+		{"Sat Sep 1 00:00 2012", "0 0 0 30W * ?", "Fri Sep 28 00:00 2012"},
+		{"Sat Sep 1 00:00 2012", "0 0 0 31W * ?", "Fri Sep 28 00:00 2012"},
+		{"Sat Sep 1 00:00 2012", "0 0 0 1,15W * ?", "Fri Sep 14 00:00 2012"},
+
+		// Days counted from the end of the month
+		{"Wed Feb 1 00:00 2012", "0 0 0 -1 * ?", "Wed Feb 29 00:00 2012"},
+		{"Sun Feb 10 00:00 2013", "0 0 0 -1 * ?", "Thu Feb 28 00:00 2013"},
+		{"Thu Feb 28 00:00 2013", "0 0 0 -1 * ?", "Sun Mar 31 00:00 2013"},
+		{"Sun Mar 31 00:00 2013", "0 0 0 -1 * ?", "Tue Apr 30 00:00 2013"},
+		{"Sun Feb 10 00:00 2013", "0 0 0 -2 * ?", "Wed Feb 27 00:00 2013"},
+		{"Fri Feb 1 00:00 2013", "0 0 0 1,-1 * ?", "Thu Feb 28 00:00 2013"},
+		{"Thu Feb 28 00:00 2013", "0 0 0 1,-1 * ?", "Fri Mar 1 00:00 2013"},
+		{"Mon Feb 1 00:00 2016", "0 0 0 -31 * ?", "Tue Mar 1 00:00 2016"},
+
 		// Unsatisfiable
 		{"Mon Jul 9 23:35 2012", "0 0 0 30 Feb ?", ""},
 		{"Mon Jul 9 23:35 2012", "0 0 0 31 Apr ?", ""},
@@ -166,12 +186,71 @@ func TestNext(t *testing.T) {
 	}
 }
 
+func TestDayAnd(t *testing.T) {
+	runs := []struct {
+		time, expected string
+		and            bool
+	}{
+		// Either the 13th or a Friday.
+		{"Sat Jun 9 00:00 2012", "Wed Jun 13 00:00 2012", false},
+		{"Sat Apr 14 00:00 2012", "Fri Apr 20 00:00 2012", false},
+
+		// Friday the 13th.
+		{"Sat Jun 9 00:00 2012", "Fri Jul 13 00:00 2012", true},
+		{"Sat Apr 14 00:00 2012", "Fri Jul 13 00:00 2012", true},
+	}
+
+	for _, c := range runs {
+		sched, err := Parse("0 0 0 13 * FRI")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.and {
+			sched = withDayAnd(sched)
+		}
+		actual := sched.Next(getTime(c.time))
+		if expected := getTime(c.expected); !actual.Equal(expected) {
+			t.Errorf("%s, and %v: (expected) %v != %v (actual)", c.time, c.and, expected, actual)
+		}
+	}
+}
+
+func TestSearchYears(t *testing.T) {
+	sched, err := Parse("0 0 0 29 Feb ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := sched.(*SpecSchedule)
+
+	// 2100 is not a leap year, so the next February 29 is 8 years away.
+	from := getTime("Thu Mar 1 00:00 2096")
+	if actual, expected := spec.Next(from), getTime("Fri Feb 29 00:00 2104"); !actual.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, actual)
+	}
+
+	spec.SearchYears = 4
+	if actual := spec.Next(from); !actual.IsZero() {
+		t.Errorf("expected no time within 4 years, got %v", actual)
+	}
+	if actual, expected := spec.Next(getTime("Mon Jul 9 23:35 2012")), getTime("Mon Feb 29 00:00 2016"); !actual.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, actual)
+	}
+}
+
 func TestErrors(t *testing.T) {
 	invalidSpecs := []string{
 		"xyz",
 		"60 0 * * *",
 		"0 60 * * *",
 		"0 0 * * XYZ",
+		"0 0 0 32W * ?",
+		"0 0 0 0W * ?",
+		"0 0 0 1-5W * ?",
+		"0 0 0 */2W * ?",
+		"0 0 0 W * ?",
+		"0 0 0 -0 * ?",
+		"0 0 0 -32 * ?",
+		"0 0 0 -1-3 * ?",
 	}
 	for _, spec := range invalidSpecs {
 		_, err := Parse(spec)
@@ -181,6 +260,29 @@ func TestErrors(t *testing.T) {
 	}
 }
 
+func TestCronTZ(t *testing.T) {
+	sched, err := Parse("CRON_TZ=America/New_York 0 0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 9am EDT is 1pm UTC.
+	actual := sched.Next(time.Date(2012, time.July, 9, 12, 0, 0, 0, time.UTC))
+	expected := time.Date(2012, time.July, 9, 13, 0, 0, 0, time.UTC)
+	if !actual.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, actual)
+	}
+	if actual.Location().String() != "America/New_York" {
+		t.Errorf("expected the time in America/New_York, got %v", actual.Location())
+	}
+
+	for _, spec := range []string{"CRON_TZ=Nowhere/Special 0 0 9 * * *", "CRON_TZ=UTC", "CRON_TZ=UTC 0 0 9"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
 func getTime(value string) time.Time {
 	if value == "" {
 		return time.Time{}
@@ -202,3 +304,82 @@ func getTime(value string) time.Time {
 
 	return t
 }
+
+func TestSchedulesEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"0 30 9 * * MON-FRI", "0 30 9 * * 1-5", true},
+		{"0 30 9 ? * MON-FRI", "0 30 9 * * mon-fri", true},
+		{"0 */15 * * * *", "0 0,15,30,45 * * * *", true},
+		{"@daily", "0 0 0 * * *", true},
+		{"@every 1h", "@every 60m", true},
+		{"CRON_TZ=UTC 0 0 9 * * *", "CRON_TZ=UTC 0 0 9 * * *", true},
+		{"@sunset+20m * * 1-5", "@sunset+20m * * MON-FRI", true},
+
+		{"0 30 9 * * *", "0 30 10 * * *", false},
+		{"0 0 0 1 * *", "0 0 0 1 * MON", false},
+		{"@every 1h", "@every 2h", false},
+		{"@every 1s", "* * * * * *", false},
+		{"CRON_TZ=UTC 0 0 9 * * *", "0 0 9 * * *", false},
+		{"@sunset", "@sunrise", false},
+	}
+	for _, test := range tests {
+		a, err := Parse(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal := SchedulesEqual(a, b); equal != test.equal {
+			t.Errorf("%q, %q: (expected) %v != %v (actual)", test.a, test.b, test.equal, equal)
+		}
+	}
+
+	a, _ := Parse("0 0 0 29 Feb ?")
+	b, _ := Parse("0 0 0 29 Feb ?")
+	b.(*SpecSchedule).SearchYears = DefaultSearchYears
+	if !SchedulesEqual(a, b) {
+		t.Error("expected the default search years to be equal to 0")
+	}
+}
+
+func TestNewSpecSchedule(t *testing.T) {
+	tests := []struct {
+		second, minute, hour, dom, month, dow []int
+		spec                                  string
+	}{
+		{[]int{0}, []int{0, 30}, []int{9}, nil, nil, []int{1, 2, 3, 4, 5}, "0 0,30 9 * * MON-FRI"},
+		{[]int{0}, []int{0}, []int{0}, []int{1}, []int{1}, nil, "@yearly"},
+		{nil, nil, nil, nil, nil, nil, "* * * * * *"},
+		{[]int{0}, []int{0}, []int{12}, nil, nil, []int{7}, "0 0 12 * * SUN"},
+		{[]int{0}, []int{0}, []int{0}, []int{13}, nil, []int{5}, "0 0 0 13 * FRI"},
+	}
+	for _, test := range tests {
+		actual, err := NewSpecSchedule(test.second, test.minute, test.hour, test.dom, test.month, test.dow)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		expected, _ := Parse(test.spec)
+		if !SchedulesEqual(actual, expected) {
+			t.Errorf("%s: (expected) %+v != %+v (actual)", test.spec, expected, actual)
+		}
+	}
+
+	for _, fields := range [][6][]int{
+		{{60}, nil, nil, nil, nil, nil},
+		{nil, {-1}, nil, nil, nil, nil},
+		{nil, nil, {24}, nil, nil, nil},
+		{nil, nil, nil, {0}, nil, nil},
+		{nil, nil, nil, nil, {13}, nil},
+		{nil, nil, nil, nil, nil, {8}},
+	} {
+		if _, err := NewSpecSchedule(fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("%v: expected ErrInvalidSpec, got %v", fields, err)
+		}
+	}
+}
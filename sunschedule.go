@@ -2,82 +2,536 @@ package cron
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jonaz/astrotime"
 )
 
+// sunStates are the sun events a SunSchedule can be triggered by.
+var sunStates = []string{"sunset", "sunrise", "dusk", "dawn", "solarnoon", "goldenhour"}
+
+// isSunState reports whether state is one of sunStates.
+func isSunState(state string) bool {
+	for _, s := range sunStates {
+		if state == s {
+			return true
+		}
+	}
+	return false
+}
+
+// twilights maps a twilight level to the sun angles astrotime uses for dawn
+// and dusk at that level.
+var twilights = map[string]struct{ dawn, dusk float64 }{
+	"civil":        {astrotime.CIVIL_DAWN, astrotime.CIVIL_DUSK},
+	"nautical":     {astrotime.NAUTICAL_DAWN, astrotime.NAUTICAL_DUSK},
+	"astronomical": {astrotime.ASTRONOMICAL_DAWN, astrotime.ASTRONOMICAL_DUSK},
+}
+
+// goldenHourAngle is the sun angle, in degrees below the horizon, at which the
+// evening golden hour starts: the sun being 6 degrees above the horizon.
+const goldenHourAngle = -6.0
+
+// sunAngle returns the sun angle, in degrees below the horizon as astrotime
+// takes it, for the dawn or dusk at the twilight level, which is either one of
+// twilights or a sun elevation in degrees, negative below the horizon. It
+// reports whether the level is valid.
+func sunAngle(state, twilight string) (float64, bool) {
+	if angles, ok := twilights[twilight]; ok {
+		if state == "dawn" {
+			return angles.dawn, true
+		}
+		return angles.dusk, true
+	}
+	elevation, err := strconv.ParseFloat(twilight, 64)
+	if err != nil || elevation <= -90 || elevation >= 90 {
+		return 0, false
+	}
+	return -elevation, true
+}
+
+// defaultTwilight is used for dusk and dawn when no level is given.
+const defaultTwilight = "civil"
+
+// splitSunState splits a state token like "dusk:nautical" into the state and
+// the twilight level, reporting whether a level was given.
+func splitSunState(token string) (state, twilight string, ok bool) {
+	if i := strings.IndexByte(token, ':'); i >= 0 {
+		return token[:i], token[i+1:], true
+	}
+	return token, "", false
+}
+
+// splitSunOffset splits a state token like "sunset+20m" into the rest of the
+// token and the offset from the sun event. The sign of a sun elevation, as in
+// "dusk:-4+20m", is not taken for the offset's.
+func splitSunOffset(token string) (string, time.Duration, error) {
+	from := 0
+	if colon := strings.IndexByte(token, ':'); colon >= 0 {
+		from = colon + 2
+	}
+	if from > len(token) {
+		return token, 0, nil
+	}
+	i := strings.IndexAny(token[from:], "+-")
+	if i < 0 {
+		return token, 0, nil
+	}
+	i += from
+	offset, err := time.ParseDuration(token[i:])
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to parse sun offset %q: %s", token[i:], err)
+	}
+	return token[:i], offset, nil
+}
+
+// The coordinates used for sun calculations, unless SetDefaultLocation is
+// called.
+const (
+	defaultLatitude  = 56.878333
+	defaultLongitude = 14.809167
+)
+
+// defaultLocation holds the coordinates new sun schedules get.
+var defaultLocation = struct {
+	sync.Mutex
+	lat, lng float64
+}{lat: defaultLatitude, lng: defaultLongitude}
+
+// SetDefaultLocation sets the coordinates of the sun schedules created after
+// it, including those parsed from specs by Parse and AddFunc. It is safe for
+// concurrent use.
+func SetDefaultLocation(lat, lng float64) error {
+	if err := checkCoordinates(lat, lng); err != nil {
+		return err
+	}
+	defaultLocation.Lock()
+	defer defaultLocation.Unlock()
+	defaultLocation.lat, defaultLocation.lng = lat, lng
+	return nil
+}
+
+// checkCoordinates returns an error if lat or lng is out of range.
+func checkCoordinates(lat, lng float64) error {
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return fmt.Errorf("Coordinates out of range: %v, %v", lat, lng)
+	}
+	return nil
+}
+
 type SunSchedule struct {
-	state  string
-	fields []string
+	state    string
+	twilight string
+	offset   time.Duration
+	fields   []string
+	days     *SpecSchedule
+	lat, lng float64
+	fallback SunFallback
+	provider SunProvider
+}
+
+// SunProvider computes sun events for a SunSchedule, e.g. with a different
+// ephemeris, or with fixed times in tests. Each method returns the first event
+// after t at the coordinates. Dawn and dusk take the sun angle in degrees below
+// the horizon, e.g. 6 for civil twilight.
+type SunProvider interface {
+	NextSunrise(t time.Time, lat, lng float64) time.Time
+	NextSunset(t time.Time, lat, lng float64) time.Time
+	NextDawn(t time.Time, lat, lng, angle float64) time.Time
+	NextDusk(t time.Time, lat, lng, angle float64) time.Time
+}
+
+// astrotimeProvider is the SunProvider backed by astrotime, the default.
+type astrotimeProvider struct{}
+
+func (astrotimeProvider) NextSunrise(t time.Time, lat, lng float64) time.Time {
+	return astrotime.NextSunrise(t, lat, lng)
+}
+
+func (astrotimeProvider) NextSunset(t time.Time, lat, lng float64) time.Time {
+	return astrotime.NextSunset(t, lat, lng)
+}
+
+func (astrotimeProvider) NextDawn(t time.Time, lat, lng, angle float64) time.Time {
+	return astrotime.NextDawn(t, lat, lng, angle)
+}
+
+func (astrotimeProvider) NextDusk(t time.Time, lat, lng, angle float64) time.Time {
+	return astrotime.NextDusk(t, lat, lng, angle)
+}
+
+// WithProvider returns a copy of the schedule that computes its sun events with
+// provider rather than astrotime. Unlike those of astrotime, its events aren't
+// cached, so it is asked again each time.
+func (s *SunSchedule) WithProvider(provider SunProvider) *SunSchedule {
+	copied := *s
+	copied.provider = provider
+	return &copied
+}
+
+// SunFallback is what a SunSchedule does on its days on which the sun event
+// doesn't occur, such as sunset in a polar summer. The zero value goes by the
+// next event astrotime finds, which may be days later.
+type SunFallback struct {
+	skip         bool
+	set          bool
+	hour, minute int
+}
+
+// SkipMissingSunEvent is the SunFallback that skips days without the event,
+// activating on the next of the schedule's days that has one.
+var SkipMissingSunEvent = SunFallback{skip: true}
+
+// FallbackTime returns the SunFallback that activates at the given time of day
+// on days without the event, e.g. FallbackTime(12, 0) for noon. The offset of
+// the schedule doesn't move it.
+func FallbackTime(hour, minute int) SunFallback {
+	return SunFallback{set: true, hour: hour, minute: minute}
+}
+
+// maxSunSkipDays is how many days Next looks ahead for a day with the event,
+// with SkipMissingSunEvent; a polar night is shorter than that.
+const maxSunSkipDays = 366
+
+// WithFallback returns a copy of the schedule that does as the fallback says
+// on days without the event. The fallback is not part of the String of the
+// schedule.
+func (s *SunSchedule) WithFallback(fallback SunFallback) *SunSchedule {
+	copied := *s
+	copied.fallback = fallback
+	return &copied
+}
+
+// sunKey identifies a sun event computation.
+type sunKey struct {
+	lat, lng float64
+	state    string
+	twilight string
+	basetime int64
 }
 
-func NewSunSchedule(state string) *SunSchedule {
+// sunCache holds computed sun events, so that Next calls for the same
+// location, day and state don't recompute them. It is safe for concurrent use.
+type sunCache struct {
+	mu     sync.Mutex
+	events map[sunKey]time.Time
+}
+
+// maxSunCacheSize bounds the number of cached sun events; the cache is
+// emptied when it is reached.
+const maxSunCacheSize = 1024
+
+var sunEvents = &sunCache{events: make(map[sunKey]time.Time)}
+
+func (c *sunCache) get(key sunKey) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.events[key]
+	return t, ok
+}
+
+func (c *sunCache) put(key sunKey, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.events) >= maxSunCacheSize {
+		c.events = make(map[sunKey]time.Time)
+	}
+	c.events[key] = t
+}
+
+// NewSunSchedule returns a schedule for the given sun spec, e.g.
+// "@sunset * * 1-5", at the coordinates set by SetDefaultLocation. Omitted dom,
+// month and dow fields default to "*".
+//
+// The dusk and dawn states accept a twilight level, one of civil (the
+// default), nautical or astronomical, e.g. "@dusk:nautical", or the sun
+// elevation in degrees at which to trigger instead, negative below the horizon:
+// "@dusk:-4" is when the sun sets to 4 degrees below it, "@dawn:10" when it
+// rises to 10 degrees above it. Any state may be followed by an offset from
+// the event, e.g. "@sunset+20m" or "@sunrise-1h".
+func NewSunSchedule(state string) (*SunSchedule, error) {
+	if len(state) == 0 || state[0] != '@' {
+		return nil, fmt.Errorf("Sun spec must start with @: %q", state)
+	}
+
 	//Remove @ in the beginning
-	state = state[1:]
-	fields := strings.Fields(state)
+	fields := strings.Fields(state[1:])
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Missing sun state: %q", state)
+	}
+	token, offset, err := splitSunOffset(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	sunState, twilight, hasTwilight := splitSunState(token)
+	if !isSunState(sunState) {
+		return nil, fmt.Errorf("Unknown sun state %q, expected one of %s: %s",
+			sunState, strings.Join(sunStates, ", "), state)
+	}
+	if !hasTwilight {
+		twilight = defaultTwilight
+	} else if sunState != "dusk" && sunState != "dawn" {
+		return nil, fmt.Errorf("Twilight level is only allowed for dusk and dawn: %s", state)
+	} else if _, ok := sunAngle(sunState, twilight); !ok {
+		return nil, fmt.Errorf("Unknown twilight level %q, expected civil, nautical, astronomical "+
+			"or a sun elevation between -90 and 90 degrees: %s", twilight, state)
+	}
+	if len(fields) > 4 {
+		return nil, fmt.Errorf("Expected at most 4 fields, found %d: %s", len(fields), state)
+	}
 
 	//Fix empty fields and set them to *
-	if len(fields) == 1 {
-		fields = append(fields, "*")
-		fields = append(fields, "*")
+	for len(fields) < 4 {
 		fields = append(fields, "*")
 	}
-	if len(fields) == 2 {
-		fields = append(fields, "*")
-		fields = append(fields, "*")
-	}
-	if len(fields) == 3 {
-		fields = append(fields, "*")
+
+	days, err := sunDays(fields[1:])
+	if err != nil {
+		return nil, err
 	}
 
-	return &SunSchedule{state: fields[0], fields: fields[1:]}
+	defaultLocation.Lock()
+	defer defaultLocation.Unlock()
+	return &SunSchedule{
+		state:    sunState,
+		twilight: twilight,
+		offset:   offset,
+		fields:   fields[1:],
+		days:     days,
+		lat:      defaultLocation.lat,
+		lng:      defaultLocation.lng,
+	}, nil
 }
 
-// next is used for getting the day when the next run shall be.
-// So it can be fed to astrotime for checking sun on the correct day
-func (s *SunSchedule) next() time.Time {
+// sunDays returns the schedule matching the days given by the dom, month and
+// dow fields of a sun spec. It is activated at the start of each of those days,
+// see dayStart, and has no bearing on the time of the event.
+func sunDays(fields []string) (_ *SpecSchedule, err error) {
+	// Convert panics into errors
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if fieldErr, ok := recovered.(*FieldError); ok {
+				err = fieldErr
+				return
+			}
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
 
-	schedule := &SpecSchedule{
-		Second: getField("1", seconds),
-		Minute: getField("*", minutes),
-		Hour:   getField("*", hours),
-		Dom:    getField(s.fields[0], dom),
-		Month:  getField(s.fields[1], months),
-		Dow:    getField(s.fields[2], dow),
+	days, nearestWeekday, fromMonthEnd := getDomField(fields[0])
+	return &SpecSchedule{
+		Second:         getField("0", seconds),
+		Minute:         getField("*", minutes),
+		Hour:           getField("*", hours),
+		Dom:            days,
+		Month:          getField(fields[1], months),
+		Dow:            getDowField(fields[2]),
+		NearestWeekday: nearestWeekday,
+		FromMonthEnd:   fromMonthEnd,
+	}, nil
+}
+
+// String returns the spec of the schedule, e.g. "@dusk:nautical * * 1-5",
+// which NewSunSchedule parses back into the same schedule. The coordinates are
+// not part of it.
+func (s *SunSchedule) String() string {
+	state := s.state
+	if s.twilight != defaultTwilight {
+		state += ":" + s.twilight
+	}
+	if s.offset > 0 {
+		state += "+"
 	}
+	if s.offset != 0 {
+		state += s.offset.String()
+	}
+	return "@" + state + " " + strings.Join(s.fields, " ")
+}
 
-	//Start of today
-	now := time.Now().Local()
-	d := time.Duration(-now.Hour()) * time.Hour
-	return schedule.Next(now.Truncate(time.Hour).Add(d))
+// Next returns the first sun event, moved by the offset, after t on one of the
+// schedule's days, taking t as now if it is the zero time. Days start at
+// midnight in t's location, e.g. the one given by a CRON_TZ prefix.
+func (s *SunSchedule) Next(t time.Time) time.Time {
+	if t.IsZero() {
+		t = time.Now()
+	}
 
+	// Try the day t is in first; its event may have gone by already, and
+	// near the poles it may fall on a later day. Midnight is built from the
+	// date rather than by subtracting the time of day, which goes wrong on
+	// days with a daylight saving transition.
+	days := 3
+	if s.fallback.skip {
+		days = maxSunSkipDays
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < days; i++ {
+		basetime := s.dayStart(day)
+		if basetime.IsZero() {
+			return basetime
+		}
+		if sun, ok := s.sunOn(basetime); ok && sun.After(t) {
+			return sun
+		}
+		day = time.Date(basetime.Year(), basetime.Month(), basetime.Day()+1, 0, 0, 0, 0, basetime.Location())
+	}
+	return time.Time{}
 }
 
-func (s *SunSchedule) Next(t time.Time) time.Time {
-	basetime := s.next()
-	fmt.Println(basetime)
+// Prev returns the sun event, moved by the offset, of the day t is in if it
+// went by already at t, or the zero time if it is still to come or the day is
+// not one of the schedule's days.
+func (s *SunSchedule) Prev(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	basetime := s.dayStart(day)
+	if basetime.IsZero() || !basetime.Before(day.AddDate(0, 0, 1)) {
+		return time.Time{}
+	}
+	if sun, ok := s.sunOn(basetime); ok && !sun.Before(day) && !sun.After(t) {
+		return sun
+	}
+	return time.Time{}
+}
 
-	if r := s.getSun(basetime); r.Before(time.Now().Local()) {
-		basetime = basetime.Add(time.Hour * 24)
-		fmt.Println("sunset/rise in the past, adding basetime +24h")
-		fmt.Println(basetime)
+// withSunLocation returns a copy of the schedule with the given coordinates, if
+// it is a sun schedule or wraps one, and reports whether it is.
+func withSunLocation(schedule Schedule, lat, lng float64) (Schedule, bool) {
+	switch s := schedule.(type) {
+	case *SunSchedule:
+		moved := *s
+		moved.lat, moved.lng = lat, lng
+		return &moved, true
+	case *locationSchedule:
+		inner, ok := withSunLocation(s.Schedule, lat, lng)
+		return &locationSchedule{inner, s.loc}, ok
+	case *jitterSchedule:
+		inner, ok := withSunLocation(s.inner, lat, lng)
+		moved := *s
+		moved.inner = inner
+		return &moved, ok
+	case *alignedSchedule:
+		inner, ok := withSunLocation(s.inner, lat, lng)
+		return &alignedSchedule{s.grid, inner}, ok
+	case *gatedSchedule:
+		inner, ok := withSunLocation(s.inner, lat, lng)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return &gatedSchedule{ready: s.ready, inner: inner, open: s.open}, ok
+	case unionSchedule:
+		moved, found := make(unionSchedule, len(s)), false
+		for i, inner := range s {
+			var ok bool
+			moved[i], ok = withSunLocation(inner, lat, lng)
+			found = found || ok
+		}
+		return moved, found
 	}
+	return schedule, false
+}
 
-	return s.getSun(basetime)
+// dayStart returns the start of the first of the schedule's days from the one
+// starting at midnight on, usually that midnight, from which astrotime looks for
+// the day's event.
+func (s *SunSchedule) dayStart(midnight time.Time) time.Time {
+	return s.days.Next(midnight.Add(-time.Nanosecond))
+}
 
+// passedSunEvent returns the sun event of the day now is in that went by
+// already, if the schedule is a sun schedule or wraps one, or the zero time. Of
+// those of a union, it returns the latest; of a gated one, none while the gate
+// is closed.
+func passedSunEvent(schedule Schedule, now time.Time) time.Time {
+	switch s := schedule.(type) {
+	case *SunSchedule:
+		return s.Prev(now)
+	case *locationSchedule:
+		return passedSunEvent(s.Schedule, now.In(s.loc))
+	case *jitterSchedule:
+		return passedSunEvent(s.inner, now)
+	case *alignedSchedule:
+		return passedSunEvent(s.inner, now)
+	case *gatedSchedule:
+		s.mu.Lock()
+		open := s.open
+		s.mu.Unlock()
+		if open {
+			return passedSunEvent(s.inner, now)
+		}
+	case unionSchedule:
+		var passed time.Time
+		for _, inner := range s {
+			if sun := passedSunEvent(inner, now); sun.After(passed) {
+				passed = sun
+			}
+		}
+		return passed
+	}
+	return time.Time{}
+}
+
+// sunOn returns the activation time of the day starting at basetime: the sun
+// event moved by the offset, or, if the event doesn't occur that day, the time
+// the fallback gives. It reports false if the fallback skips the day.
+func (s *SunSchedule) sunOn(basetime time.Time) (time.Time, bool) {
+	sun := s.getSun(basetime)
+	end := time.Date(basetime.Year(), basetime.Month(), basetime.Day()+1, 0, 0, 0, 0, basetime.Location())
+	if !sun.IsZero() && sun.Before(end) {
+		return sun.Add(s.offset), true
+	}
+	switch {
+	case s.fallback.skip:
+		return time.Time{}, false
+	case s.fallback.set:
+		return time.Date(basetime.Year(), basetime.Month(), basetime.Day(),
+			s.fallback.hour, s.fallback.minute, 0, 0, basetime.Location()), true
+	}
+	return sun.Add(s.offset), !sun.IsZero()
 }
+
+// getSun returns the sun event following basetime, from the cache if it has
+// been computed before.
 func (s *SunSchedule) getSun(basetime time.Time) time.Time {
+	if s.provider != nil {
+		return s.calcSun(s.provider, basetime)
+	}
+	key := sunKey{
+		lat:      s.lat,
+		lng:      s.lng,
+		state:    s.state,
+		twilight: s.twilight,
+		basetime: basetime.UnixNano(),
+	}
+	if t, ok := sunEvents.get(key); ok {
+		return t
+	}
+	t := s.calcSun(astrotimeProvider{}, basetime)
+	sunEvents.put(key, t)
+	return t
+}
+
+// calcSun computes the sun event following basetime with the provider.
+func (s *SunSchedule) calcSun(p SunProvider, basetime time.Time) time.Time {
 	switch s.state {
 	case "sunset":
-		return astrotime.NextSunset(basetime, float64(56.878333), float64(14.809167))
+		return p.NextSunset(basetime, s.lat, s.lng)
 	case "sunrise":
-		return astrotime.NextSunrise(basetime, float64(56.878333), float64(14.809167))
+		return p.NextSunrise(basetime, s.lat, s.lng)
 	case "dusk":
-		return astrotime.NextDusk(basetime, float64(56.878333), float64(14.809167), astrotime.CIVIL_DUSK)
+		angle, _ := sunAngle(s.state, s.twilight)
+		return p.NextDusk(basetime, s.lat, s.lng, angle)
 	case "dawn":
-		return astrotime.NextDawn(basetime, float64(56.878333), float64(14.809167), astrotime.CIVIL_DAWN)
+		angle, _ := sunAngle(s.state, s.twilight)
+		return p.NextDawn(basetime, s.lat, s.lng, angle)
+	case "solarnoon":
+		// Solar noon lies halfway between sunrise and sunset.
+		sunrise := p.NextSunrise(basetime, s.lat, s.lng)
+		sunset := p.NextSunset(sunrise, s.lat, s.lng)
+		return sunrise.Add(sunset.Sub(sunrise) / 2)
+	case "goldenhour":
+		return p.NextDusk(basetime, s.lat, s.lng, goldenHourAngle)
 	}
 
 	return time.Time{}
@@ -1,13 +1,20 @@
 package cron
 
 import (
+	"context"
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/jonaz/astrotime"
 )
 
 func TestSunScheduleNextSunset(t *testing.T) {
 
-	s := NewSunSchedule("@sunset")
+	s, err := NewSunSchedule("@sunset")
+	if err != nil {
+		t.Fatal(err)
+	}
 	t.Log(s)
 
 	t1 := s.Next(time.Now())
@@ -16,7 +23,10 @@ func TestSunScheduleNextSunset(t *testing.T) {
 
 func TestSunScheduleNextSunrise(t *testing.T) {
 
-	s := NewSunSchedule("@sunrise")
+	s, err := NewSunSchedule("@sunrise")
+	if err != nil {
+		t.Fatal(err)
+	}
 	t.Log(s)
 
 	t1 := s.Next(time.Now())
@@ -25,7 +35,10 @@ func TestSunScheduleNextSunrise(t *testing.T) {
 
 func TestSunScheduleNextSunsetOtherDate(t *testing.T) {
 
-	s := NewSunSchedule("@sunset * * 0")
+	s, err := NewSunSchedule("@sunset * * 0")
+	if err != nil {
+		t.Fatal(err)
+	}
 	t.Log(s)
 
 	t1 := s.Next(time.Now())
@@ -34,9 +47,606 @@ func TestSunScheduleNextSunsetOtherDate(t *testing.T) {
 
 func TestSunScheduleNextSunriseOtherDate(t *testing.T) {
 
-	s := NewSunSchedule("@sunrise * * 0")
+	s, err := NewSunSchedule("@sunrise * * 0")
+	if err != nil {
+		t.Fatal(err)
+	}
 	t.Log(s)
 
 	t1 := s.Next(time.Now())
 	t.Log(t1)
 }
+
+// Test that Next is relative to the given time, not to now.
+func TestSunScheduleNextFromTime(t *testing.T) {
+	s, err := NewSunSchedule("@sunset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, from := range []time.Time{
+		time.Date(2030, time.July, 9, 0, 0, 0, 0, time.Local),
+		time.Date(2030, time.July, 9, 23, 0, 0, 0, time.Local),
+		time.Date(2012, time.January, 9, 12, 0, 0, 0, time.Local),
+	} {
+		next := s.Next(from)
+		if !next.After(from) || next.After(from.AddDate(0, 0, 2)) {
+			t.Errorf("%v: expected the sunset after it, got %v", from, next)
+		}
+		if again := s.Next(next); !again.After(next) || again.Day() == next.Day() {
+			t.Errorf("%v: expected the next day's sunset after %v, got %v", from, next, again)
+		}
+	}
+
+	if next := s.Next(time.Time{}); !next.After(time.Now()) {
+		t.Errorf("expected the zero time to mean now, got %v", next)
+	}
+}
+
+// Test that Next skips to the schedule's days.
+func TestSunScheduleNextDays(t *testing.T) {
+	s, err := NewSunSchedule("@sunrise * * 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2030, time.July, 9, 12, 0, 0, 0, time.Local) // a Tuesday
+	if next := s.Next(from); next.Weekday() != time.Sunday || next.Day() != 14 {
+		t.Errorf("expected sunrise on Sunday the 14th, got %v", next)
+	}
+}
+
+// Test that the day Next looks at is the one t is in, in its location, on the
+// day daylight saving time starts there.
+func TestSunScheduleNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	s, err := NewSunSchedule("@sunrise")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clocks go from 2:00 to 3:00 that night.
+	from := time.Date(2012, time.March, 11, 1, 30, 0, 0, loc)
+	if next := s.Next(from).In(loc); next.Day() != 11 {
+		t.Errorf("expected sunrise on March 11, got %v", next)
+	}
+}
+
+func TestSunSchedulePrev(t *testing.T) {
+	s, err := NewSunSchedule("@solarnoon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	late := time.Date(2012, time.July, 9, 23, 30, 0, 0, time.Local)
+	prev := s.Prev(late)
+	if prev.IsZero() || prev.After(late) || prev.Day() != 9 {
+		t.Fatalf("expected the solar noon of July 9 before %v, got %v", late, prev)
+	}
+	if next := s.Next(prev.Add(-time.Second)); !next.Equal(prev) {
+		t.Errorf("expected Next to give the same event, %v != %v", prev, next)
+	}
+	if early := s.Prev(prev.Add(-time.Second)); !early.IsZero() {
+		t.Errorf("expected no event yet before %v, got %v", prev, early)
+	}
+}
+
+// Test that with WithCatchUp a sun job added after the day's event runs right
+// away.
+func TestSunCatchUp(t *testing.T) {
+	for _, catchUp := range []bool{false, true} {
+		clock := NewFakeClock(time.Date(2012, time.July, 9, 23, 30, 0, 0, time.Local))
+		ran := make(chan struct{}, 1)
+		opts := []Option{WithClock(clock)}
+		if catchUp {
+			opts = append(opts, WithCatchUp())
+		}
+		cron := New(opts...)
+		if _, err := cron.AddFunc("@solarnoon", func() { ran <- struct{}{} }); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cron.Start(ctx)
+
+		select {
+		case <-ran:
+			if !catchUp {
+				t.Error("expected the job not to run without catch-up")
+			}
+		case <-time.After(100 * time.Millisecond):
+			if catchUp {
+				t.Error("expected the job to run right away with catch-up")
+			}
+		}
+		cancel()
+	}
+}
+
+func TestSunScheduleString(t *testing.T) {
+	for _, c := range []struct{ spec, expected string }{
+		{"@sunset", "@sunset * * *"},
+		{"@dusk", "@dusk * * *"},
+		{"@dusk:civil", "@dusk * * *"},
+		{"@dawn:astronomical 1 *", "@dawn:astronomical 1 * *"},
+		{"@sunrise 15W 1-6 MON-FRI", "@sunrise 15W 1-6 MON-FRI"},
+		{"@sunset+20m", "@sunset+20m0s * * *"},
+		{"@dusk:nautical-1h30m * * 1-5", "@dusk:nautical-1h30m0s * * 1-5"},
+		{"@dusk:-4", "@dusk:-4 * * *"},
+		{"@dawn:2.5-10m", "@dawn:2.5-10m0s * * *"},
+	} {
+		s, err := NewSunSchedule(c.spec)
+		if err != nil {
+			t.Errorf("%s: %v", c.spec, err)
+			continue
+		}
+		if actual := s.String(); actual != c.expected {
+			t.Errorf("%s: (expected) %q != %q (actual)", c.spec, c.expected, actual)
+		}
+		again, err := NewSunSchedule(s.String())
+		if err != nil || !reflect.DeepEqual(again, s) {
+			t.Errorf("%s: %q parses back to %+v (%v), expected %+v", c.spec, s.String(), again, err, s)
+		}
+	}
+}
+
+func TestNewSunScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "@", "@ ", "sunset", "@sunset * * * *", "@sunsett", "@noon * * *",
+		"@dusk:", "@dusk:deep", "@sunset:civil", "@sunset+", "@sunset+20", "@sunset-soon",
+		"@dusk:-90", "@dawn:120", "@sunset:-4", "@dusk:-4+"} {
+		if _, err := NewSunSchedule(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestNewSunScheduleDefaults(t *testing.T) {
+	s, err := NewSunSchedule("@sunset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.state != "sunset" || len(s.fields) != 3 {
+		t.Errorf("unexpected schedule: %+v", s)
+	}
+	for _, f := range s.fields {
+		if f != "*" {
+			t.Errorf("expected omitted fields to default to *, got %v", s.fields)
+		}
+	}
+}
+
+func TestParseSunSpecInvalid(t *testing.T) {
+	if _, err := Parse("@sunset * * * * *"); err == nil {
+		t.Error("expected an error for too many fields")
+	}
+}
+
+func TestNewSunScheduleStates(t *testing.T) {
+	for _, state := range sunStates {
+		if _, err := NewSunSchedule("@" + state); err != nil {
+			t.Errorf("%s: %v", state, err)
+		}
+	}
+}
+
+func TestNewSunScheduleTwilight(t *testing.T) {
+	tests := []struct {
+		spec, twilight string
+	}{
+		{"@dusk", "civil"},
+		{"@dawn:civil", "civil"},
+		{"@dusk:nautical * * 1-5", "nautical"},
+		{"@dawn:astronomical", "astronomical"},
+	}
+
+	for _, c := range tests {
+		s, err := NewSunSchedule(c.spec)
+		if err != nil {
+			t.Errorf("%s: %v", c.spec, err)
+			continue
+		}
+		if s.twilight != c.twilight {
+			t.Errorf("%s: (expected) %s != %s (actual)", c.spec, c.twilight, s.twilight)
+		}
+	}
+
+	if _, err := Parse("@dusk:nautical * * 1-5"); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test that a sun elevation is handed to astrotime as the angle below the
+// horizon.
+func TestSunScheduleElevation(t *testing.T) {
+	s, err := NewSunSchedule("@dusk:-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	basetime := time.Date(2012, time.July, 9, 0, 0, 0, 0, time.Local)
+	expected := astrotime.NextDusk(basetime, 56.878333, 14.809167, 4)
+	if dusk := s.getSun(basetime); !dusk.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, dusk)
+	}
+	if sunset := astrotime.NextSunset(basetime, 56.878333, 14.809167); !expected.After(sunset) {
+		t.Errorf("dusk at -4 degrees %v should come after sunset %v", expected, sunset)
+	}
+}
+
+// Test that Next returns the event astrotime computes from the start of the
+// day, to the second, rather than anything pinned to a second of the spec.
+func TestSunScheduleNextExact(t *testing.T) {
+	midnight := time.Date(2012, time.July, 9, 0, 0, 0, 0, time.Local)
+	for _, c := range []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@sunset", astrotime.NextSunset(midnight, 56.878333, 14.809167)},
+		{"@sunrise", astrotime.NextSunrise(midnight, 56.878333, 14.809167)},
+		{"@dusk:nautical", astrotime.NextDusk(midnight, 56.878333, 14.809167, astrotime.NAUTICAL_DUSK)},
+	} {
+		s, err := NewSunSchedule(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if basetime := s.dayStart(midnight); !basetime.Equal(midnight) {
+			t.Errorf("%s: expected the day to start at %v, got %v", c.spec, midnight, basetime)
+		}
+		next := s.Next(midnight)
+		if !next.Equal(c.expected) || next.Second() != c.expected.Second() {
+			t.Errorf("%s: (expected) %v != %v (actual)", c.spec, c.expected, next)
+		}
+	}
+}
+
+func TestSunScheduleFallback(t *testing.T) {
+	s, err := NewSunSchedule("@sunset+1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A polar summer: no sunset on the 9th, and on the 10th the next one
+	// astrotime finds is weeks later.
+	s.lat, s.lng = 78.22, 15.65
+	july9 := time.Date(2012, time.July, 9, 0, 0, 0, 0, time.Local)
+	july10 := july9.AddDate(0, 0, 1)
+	later := time.Date(2012, time.August, 20, 23, 0, 0, 0, time.Local)
+	seed := func() {
+		sunEvents.put(sunKey{s.lat, s.lng, s.state, s.twilight, july9.UnixNano()}, time.Time{})
+		sunEvents.put(sunKey{s.lat, s.lng, s.state, s.twilight, july10.UnixNano()}, later)
+	}
+
+	for _, c := range []struct {
+		name     string
+		schedule *SunSchedule
+		expected time.Time
+	}{
+		{"none", s, later.Add(time.Hour)},
+		{"skip", s.WithFallback(SkipMissingSunEvent), time.Date(2012, time.July, 11, 19, 0, 0, 0, time.Local)},
+		{"noon", s.WithFallback(FallbackTime(12, 0)), time.Date(2012, time.July, 9, 12, 0, 0, 0, time.Local)},
+	} {
+		seed()
+		if next := c.schedule.Next(july9); !next.Equal(c.expected) {
+			t.Errorf("%s: (expected) %v != %v (actual)", c.name, c.expected, next)
+		}
+	}
+
+	seed()
+	noon := s.WithFallback(FallbackTime(12, 0))
+	if prev, expected := noon.Prev(july9.Add(13*time.Hour)), july9.Add(12*time.Hour); !prev.Equal(expected) {
+		t.Errorf("prev: (expected) %v != %v (actual)", expected, prev)
+	}
+	if prev := s.WithFallback(SkipMissingSunEvent).Prev(july9.Add(23 * time.Hour)); !prev.IsZero() {
+		t.Errorf("prev: expected no event on a skipped day, got %v", prev)
+	}
+	if noon.String() != s.String() {
+		t.Errorf("expected the fallback to be left out of %q", noon.String())
+	}
+}
+
+// fixedSun is a SunProvider with the same events every day, dawn and dusk an
+// hour per degree of sun angle before sunrise and after sunset.
+type fixedSun struct{ sunrise, sunset time.Duration }
+
+func (f fixedSun) next(t time.Time, at time.Duration) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if event := day.Add(at); event.After(t) {
+		return event
+	}
+	return day.AddDate(0, 0, 1).Add(at)
+}
+
+func (f fixedSun) NextSunrise(t time.Time, lat, lng float64) time.Time {
+	return f.next(t, f.sunrise)
+}
+
+func (f fixedSun) NextSunset(t time.Time, lat, lng float64) time.Time {
+	return f.next(t, f.sunset)
+}
+
+func (f fixedSun) NextDawn(t time.Time, lat, lng, angle float64) time.Time {
+	return f.next(t, f.sunrise-time.Duration(angle)*time.Hour)
+}
+
+func (f fixedSun) NextDusk(t time.Time, lat, lng, angle float64) time.Time {
+	return f.next(t, f.sunset+time.Duration(angle)*time.Hour)
+}
+
+func TestSunScheduleProvider(t *testing.T) {
+	provider := fixedSun{sunrise: 5*time.Hour + 15*time.Minute, sunset: 21*time.Hour + 40*time.Minute}
+	from := time.Date(2012, time.July, 9, 12, 0, 0, 0, time.Local)
+	for _, c := range []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@sunset", time.Date(2012, time.July, 9, 21, 40, 0, 0, time.Local)},
+		{"@sunrise", time.Date(2012, time.July, 10, 5, 15, 0, 0, time.Local)},
+		{"@sunset+10m * * THU", time.Date(2012, time.July, 12, 21, 50, 0, 0, time.Local)},
+		{"@dusk:-2", time.Date(2012, time.July, 9, 23, 40, 0, 0, time.Local)},
+		{"@dawn:-1", time.Date(2012, time.July, 10, 4, 15, 0, 0, time.Local)},
+		{"@solarnoon", time.Date(2012, time.July, 9, 13, 27, 30, 0, time.Local)},
+	} {
+		s, err := NewSunSchedule(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := s.WithProvider(provider).Next(from); !next.Equal(c.expected) {
+			t.Errorf("%s: (expected) %v != %v (actual)", c.spec, c.expected, next)
+		}
+	}
+}
+
+func TestSunScheduleSolarNoon(t *testing.T) {
+	s, err := NewSunSchedule("@solarnoon")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basetime := time.Date(2012, time.July, 9, 0, 0, 0, 0, time.Local)
+	noon := s.getSun(basetime)
+	sunrise := astrotime.NextSunrise(basetime, 56.878333, 14.809167)
+	sunset := astrotime.NextSunset(basetime, 56.878333, 14.809167)
+	if !noon.After(sunrise) || !noon.Before(sunset) {
+		t.Errorf("solar noon %v not between sunrise %v and sunset %v", noon, sunrise, sunset)
+	}
+}
+
+func TestSunScheduleGoldenHour(t *testing.T) {
+	s, err := NewSunSchedule("@goldenhour")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basetime := time.Date(2012, time.July, 9, 0, 0, 0, 0, time.Local)
+	golden := s.getSun(basetime)
+	if sunset := astrotime.NextSunset(basetime, 56.878333, 14.809167); !golden.Before(sunset) {
+		t.Errorf("golden hour %v should start before sunset %v", golden, sunset)
+	}
+}
+
+func TestSunScheduleCache(t *testing.T) {
+	s, err := NewSunSchedule("@sunset")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basetime := time.Date(2012, time.July, 9, 0, 0, 0, 0, time.Local)
+	key := sunKey{s.lat, s.lng, s.state, s.twilight, basetime.UnixNano()}
+	expected := s.getSun(basetime)
+	if cached, ok := sunEvents.get(key); !ok || cached != expected {
+		t.Fatalf("expected %v to be cached, got %v (%v)", expected, cached, ok)
+	}
+
+	// Another location on the same day is computed separately.
+	other := *s
+	other.lat, other.lng = 0, 0
+	other.getSun(basetime)
+	if cached, _ := sunEvents.get(key); cached != expected {
+		t.Errorf("cache entry was overwritten by another location: %v", cached)
+	}
+}
+
+func TestSunCacheBounded(t *testing.T) {
+	c := &sunCache{events: make(map[sunKey]time.Time)}
+	for i := 0; i < 2*maxSunCacheSize; i++ {
+		c.put(sunKey{basetime: int64(i)}, time.Time{})
+	}
+	if len(c.events) > maxSunCacheSize {
+		t.Errorf("cache grew to %d entries", len(c.events))
+	}
+}
+
+func TestAddSunFunc(t *testing.T) {
+	cron := New()
+	id, err := cron.AddSunFunc("dusk:nautical", 59.33, 18.07, func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := cron.Entries()
+	if len(entries) != 1 || entries[0].ID != id {
+		t.Fatalf("expected one entry with id %d, got %v", id, entries)
+	}
+	s, ok := entries[0].Schedule.(*SunSchedule)
+	if !ok {
+		t.Fatalf("expected a *SunSchedule, got %T", entries[0].Schedule)
+	}
+	if s.state != "dusk" || s.twilight != "nautical" || s.lat != 59.33 || s.lng != 18.07 {
+		t.Errorf("unexpected schedule: %+v", s)
+	}
+	if entries[0].Spec != "@dusk:nautical" {
+		t.Errorf("expected the spec @dusk:nautical, got %q", entries[0].Spec)
+	}
+}
+
+// Test that AddSunFunc applies WithDayAnd like the other ways of adding a job.
+func TestAddSunFuncDayAnd(t *testing.T) {
+	cron := New(WithDayAnd())
+	id, err := cron.AddSunFunc("sunset 15 * MON", 59.33, 18.07, func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := cron.EntryByID(id)
+	if s := entry.Schedule.(*SunSchedule); !s.days.DayAnd {
+		t.Errorf("expected DayAnd to be set on the days of the schedule")
+	}
+}
+
+func TestAddSunFuncInvalid(t *testing.T) {
+	cron := New()
+	if _, err := cron.AddSunFunc("daily", 59.33, 18.07, func() {}); err == nil {
+		t.Error("expected an error for a descriptor that is not a sun state")
+	}
+	if _, err := cron.AddSunFunc("sunsett", 59.33, 18.07, func() {}); err == nil {
+		t.Error("expected an error for an unknown state")
+	}
+	if _, err := cron.AddSunFunc("sunset", 91, 18.07, func() {}); err == nil {
+		t.Error("expected an error for an out of range latitude")
+	}
+	if len(cron.Entries()) != 0 {
+		t.Error("no entries should have been added")
+	}
+}
+
+// Test that UpdateSunLocation moves the sun entries, and only those, to the new
+// coordinates.
+func TestUpdateSunLocation(t *testing.T) {
+	start := time.Date(2030, time.July, 8, 12, 0, 0, 0, time.Local)
+	cron := New(WithClock(NewFakeClock(start)))
+	sunset, _ := cron.AddSunFunc("sunset", 59.33, 18.07, func() {})
+	dusk, _ := cron.AddFunc("CRON_TZ=UTC @dusk:nautical", func() {})
+	hourly, _ := cron.AddFunc("0 0 * * * ?", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+	before, _ := cron.GetNextRun(hourly)
+
+	if err := cron.UpdateSunLocation(91, 0); err == nil {
+		t.Error("expected an error for an out of range latitude")
+	}
+	if err := cron.UpdateSunLocation(-33.87, 151.21); err != nil {
+		t.Fatal(err)
+	}
+	for id, spec := range map[int64]string{sunset: "@sunset", dusk: "@dusk:nautical"} {
+		entry, _ := cron.EntryByID(id)
+		schedule := entry.Schedule
+		if s, ok := schedule.(*locationSchedule); ok {
+			schedule = s.Schedule
+		}
+		s := schedule.(*SunSchedule)
+		if s.lat != -33.87 || s.lng != 151.21 {
+			t.Errorf("%s: expected the new coordinates, got %v, %v", spec, s.lat, s.lng)
+		}
+		if expected := entry.Schedule.Next(start); !entry.Next.Equal(expected) {
+			t.Errorf("%s: (expected) %v != %v (actual)", spec, expected, entry.Next)
+		}
+	}
+	if after, _ := cron.GetNextRun(hourly); !after.Equal(before) {
+		t.Errorf("expected the spec entry to be left alone, next %v became %v", before, after)
+	}
+}
+
+// Test that UpdateSunLocation moves the sun schedules wrapped in others too.
+func TestUpdateSunLocationWrapped(t *testing.T) {
+	start := time.Date(2030, time.July, 8, 12, 0, 0, 0, time.Local)
+	cron := New(WithClock(NewFakeClock(start)))
+	wrappers := map[string]func(Schedule) Schedule{
+		"AnyOf":   func(s Schedule) Schedule { return AnyOf(Every(time.Hour), s) },
+		"AlignTo": func(s Schedule) Schedule { return AlignTo(time.Minute, s) },
+		"GatedBy": func(s Schedule) Schedule { return GatedBy(func() bool { return true }, s) },
+	}
+	ids := map[string]int64{}
+	for name, wrap := range wrappers {
+		sunset, _ := NewSunSchedule("@sunset")
+		sunset.lat, sunset.lng = 59.33, 18.07
+		ids[name] = cron.AddSchedule(wrap(sunset), FuncJob(func() {}))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	if err := cron.UpdateSunLocation(-33.87, 151.21); err != nil {
+		t.Fatal(err)
+	}
+	for name, id := range ids {
+		entry, _ := cron.EntryByID(id)
+		var sun *SunSchedule
+		for schedules := []Schedule{entry.Schedule}; len(schedules) > 0; schedules = schedules[1:] {
+			if s, ok := schedules[0].(*SunSchedule); ok {
+				sun = s
+			}
+			schedules = append(schedules, innerSchedules(schedules[0])...)
+		}
+		if sun == nil || sun.lat != -33.87 || sun.lng != 151.21 {
+			t.Errorf("%s: expected the sun schedule moved to the new coordinates, got %+v", name, sun)
+		}
+	}
+}
+
+func TestSunScheduleOffset(t *testing.T) {
+	s, err := NewSunSchedule("@sunset-1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := NewSunSchedule("@sunset")
+	from := time.Date(2030, time.July, 9, 0, 0, 0, 0, time.Local)
+	if next, expected := s.Next(from), plain.Next(from).Add(-time.Hour); !next.Equal(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, next)
+	}
+
+	// Between the moved and the actual event, the next one is a day later.
+	from = plain.Next(from).Add(-time.Minute)
+	if next := s.Next(from); !next.After(from) || next.Day() == from.Day() {
+		t.Errorf("expected the next day's event after %v, got %v", from, next)
+	}
+}
+
+func TestSetDefaultLocation(t *testing.T) {
+	defer SetDefaultLocation(defaultLatitude, defaultLongitude)
+	if err := SetDefaultLocation(59.33, 18.07); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDefaultLocation(91, 18.07); err == nil {
+		t.Error("expected an error for an out of range latitude")
+	}
+
+	schedule, err := Parse("@sunset+20m * * MON-FRI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := schedule.(*SunSchedule)
+	if !ok {
+		t.Fatalf("expected a *SunSchedule, got %T", schedule)
+	}
+	if s.lat != 59.33 || s.lng != 18.07 || s.offset != 20*time.Minute {
+		t.Errorf("unexpected schedule: %+v", s)
+	}
+}
+
+// Test that a sun spec with an offset added with AddFunc runs at the moved
+// event, at the default location.
+func TestAddFuncSunOffset(t *testing.T) {
+	defer SetDefaultLocation(defaultLatitude, defaultLongitude)
+	SetDefaultLocation(59.33, 18.07)
+
+	start := time.Date(2030, time.July, 8, 0, 0, 0, 0, time.Local) // a Monday
+	plain, _ := NewSunSchedule("@sunset * * MON-FRI")
+	expected := plain.Next(start).Add(20 * time.Minute)
+
+	clock := NewFakeClock(start)
+	ran := make(chan time.Time, 1)
+	cron := New(WithClock(clock))
+	if _, err := cron.AddFunc("@sunset+20m * * MON-FRI", func() { ran <- clock.Now() }); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cron.Start(ctx)
+
+	clock.BlockUntil(1)
+	clock.Advance(expected.Sub(start))
+	select {
+	case at := <-ran:
+		if !at.Equal(expected) {
+			t.Errorf("(expected) %v != %v (actual)", expected, at)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Fatalf("expected the job to run at %v", expected)
+	}
+}
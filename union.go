@@ -0,0 +1,45 @@
+package cron
+
+import "time"
+
+// unionSchedule activates whenever any of its schedules does.
+type unionSchedule []Schedule
+
+// AnyOf returns a Schedule that activates at the activation times of all of
+// the given schedules, for a job whose times no single spec can express, e.g.
+// weekdays at 9:00 and Sundays at 18:00. Where they activate at the same time,
+// it activates once.
+//
+// Each time, the schedules are asked for their next activation after the last
+// one of the union, so a delay schedule like "@every 1h" counts from that
+// rather than from its own last activation.
+func AnyOf(schedules ...Schedule) Schedule {
+	return unionSchedule(schedules)
+}
+
+// Next returns the earliest next activation time of the schedules, or the zero
+// time if none is activated again.
+func (s unionSchedule) Next(t time.Time) time.Time {
+	next, _ := s.nextProbe(t)
+	return next
+}
+
+// nextProbe is like Next, but also reports whether the time is a probe, see
+// GatedBy. It is only if it is one of every schedule activating at it.
+func (s unionSchedule) nextProbe(t time.Time) (time.Time, bool) {
+	var (
+		next  time.Time
+		probe bool
+	)
+	for _, schedule := range s {
+		n, p := nextProbe(schedule, t)
+		switch {
+		case n.IsZero():
+		case next.IsZero() || n.Before(next):
+			next, probe = n, p
+		case n.Equal(next):
+			probe = probe && p
+		}
+	}
+	return next, probe
+}
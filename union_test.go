@@ -0,0 +1,30 @@
+package cron
+
+import "testing"
+
+func TestAnyOf(t *testing.T) {
+	weekdays, _ := Parse("0 0 9 * * MON-FRI")
+	sundays, _ := Parse("0 0 18 * * SUN")
+	never, _ := Parse("0 0 0 30 2 *")
+	s := AnyOf(weekdays, never, sundays, weekdays)
+
+	from := getTime("Thu Jul 12 12:00 2012")
+	for _, expected := range []string{
+		"Fri Jul 13 09:00 2012",
+		"Sun Jul 15 18:00 2012",
+		"Mon Jul 16 09:00 2012",
+		"Tue Jul 17 09:00 2012",
+	} {
+		from = s.Next(from)
+		if !from.Equal(getTime(expected)) {
+			t.Errorf("(expected) %v != %v (actual)", expected, from)
+		}
+	}
+
+	if next := AnyOf(never).Next(from); !next.IsZero() {
+		t.Errorf("expected the zero time, got %v", next)
+	}
+	if next := AnyOf().Next(from); !next.IsZero() {
+		t.Errorf("expected the zero time for no schedules, got %v", next)
+	}
+}